@@ -58,27 +58,7 @@ func (me *Dictionary) Remove(terms []vectors.Element) int {
 //
 // Returns the term freqency feature vector in sorted order by increasing Term.Id.
 func (me *Dictionary) Vectorize(words []string) vectors.SparseVector {
-	// Calculate the word frequency for each unique word in the vector
-	word2freq := make(map[string]int, len(words))
-	for _, word := range words {
-		word2freq[word]++
-	}
-
-	terms := make([]vectors.Element, 0, len(word2freq))
-
-	for word, freq := range word2freq {
-		termId, found := me.word2id[word]
-		if found {
-			term := vectors.Element{
-				Id:    termId,
-				Value: float64(freq) / float64(len(words)),
-			}
-			terms = append(terms, term)
-		}
-	}
-
-	sort.Sort(vectors.ByElementId(terms))
-	return terms
+	return me.vectorize(words, false, true)
 }
 
 // This method does what Vectorize() does, and additionally adds new terms that
@@ -86,6 +66,31 @@ func (me *Dictionary) Vectorize(words []string) vectors.SparseVector {
 //
 // Returns the term frequency feature vector in sorted order by increasing Element.Id.
 func (me *Dictionary) VectorizeAndUpdate(words []string) vectors.SparseVector {
+	return me.vectorize(words, true, true)
+}
+
+// This method does what Vectorize() does, except that each term's value is its
+// raw count within words rather than its relative frequency (i.e. a
+// "bag-of-words" vector).
+//
+// Returns the term count vector in sorted order by increasing Element.Id.
+func (me *Dictionary) VectorizeCounts(words []string) vectors.SparseVector {
+	return me.vectorize(words, false, false)
+}
+
+// This method does what VectorizeCounts() does, and additionally adds new terms
+// that are encountered into the underlying Dictionary.
+//
+// Returns the term count vector in sorted order by increasing Element.Id.
+func (me *Dictionary) VectorizeCountsAndUpdate(words []string) vectors.SparseVector {
+	return me.vectorize(words, true, false)
+}
+
+// Converts words into a term vector.  If update is true, new terms are added to
+// this Dictionary.  If relative is true, term values are relative frequencies;
+// otherwise they are raw counts.
+func (me *Dictionary) vectorize(words []string, update bool, relative bool) vectors.SparseVector {
+	// Calculate the word frequency for each unique word in the vector
 	word2freq := make(map[string]int, len(words))
 	for _, word := range words {
 		word2freq[word]++
@@ -96,17 +101,20 @@ func (me *Dictionary) VectorizeAndUpdate(words []string) vectors.SparseVector {
 	for word, freq := range word2freq {
 		termId, found := me.word2id[word]
 		if !found {
+			if !update {
+				continue
+			}
 			termId = me.nextTermId
 			me.word2id[word] = termId
 			me.id2word[termId] = word
 			me.nextTermId++
 		}
 
-		term := vectors.Element{
-			Id:    termId,
-			Value: float64(freq) / float64(len(words)),
+		value := float64(freq)
+		if relative {
+			value /= float64(len(words))
 		}
-		terms = append(terms, term)
+		terms = append(terms, vectors.Element{Id: termId, Value: value})
 	}
 
 	sort.Sort(vectors.ByElementId(terms))
@@ -117,3 +117,28 @@ func TestDictionary_SaveAndLoad(t *testing.T) {
 		assert.Equal(t, 3, d.nextTermId)
 	}
 }
+
+func TestDictionary_VectorizeCounts(t *testing.T) {
+	d := &Dictionary{
+		word2id:    map[string]int{"a": 1, "b": 2, "c": 3},
+		id2word:    map[int]string{1: "a", 2: "b", 3: "c"},
+		nextTermId: 4,
+	}
+
+	vec := d.VectorizeCounts([]string{"c", "a", "a", "Z", "Z", "Z"})
+	assert.Equal(t, vectors.SparseVector{{Id: 1, Value: 2}, {Id: 3, Value: 1}}, vec)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3}, d.word2id)
+}
+
+func TestDictionary_VectorizeCountsAndUpdate(t *testing.T) {
+	d := &Dictionary{
+		word2id:    map[string]int{"a": 1, "b": 2, "c": 3},
+		id2word:    map[int]string{1: "a", 2: "b", 3: "c"},
+		nextTermId: 4,
+	}
+
+	vec := d.VectorizeCountsAndUpdate([]string{"c", "a", "a", "Z", "Z", "Z"})
+	assert.Equal(t, vectors.SparseVector{{Id: 1, Value: 2}, {Id: 3, Value: 1}, {Id: 4, Value: 3}}, vec)
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3, "Z": 4}, d.word2id)
+	assert.Equal(t, "Z", d.Word(4))
+}
@@ -0,0 +1,174 @@
+package gosim
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of special entities that can be recognized by MakeEntityTokenizer().
+type EntityType int
+
+const (
+	EntityURL      EntityType = iota // e.g. "https://example.com/a?b=c" or "www.example.com"
+	EntityEmail                      // e.g. "john.smith@example.com"
+	EntityMention                    // e.g. "@golang"
+	EntityHashtag                    // e.g. "#nlp"
+	EntityCurrency                   // e.g. "$200" or "€1,000.50"
+	EntityVersion                    // e.g. "1.2.3" or "v2.0.1"
+	EntityNumber                     // e.g. "42", "3.14" or "1,000"
+)
+
+// The order in which entity patterns are tried when more than one of them
+// could match at the same position.
+var entityPatterns = []struct {
+	entityType EntityType
+	pattern    string
+}{
+	{EntityURL, `(?i:\b(?:https?://|www\.)[^\s<>"]+)`},
+	{EntityEmail, `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`},
+	{EntityMention, `@\w+`},
+	{EntityHashtag, `#\w*[\pL_]\w*`},
+	{EntityCurrency, `[$€£¥]\d+(?:[.,]\d+)*\b`},
+	{EntityVersion, `\bv?\d+(?:\.\d+){2,}\b`},
+	{EntityNumber, `\b\d+(?:[.,]\d+)*\b`},
+}
+
+// Returns the default normalization placeholder for this entity type.
+func (t EntityType) Placeholder() string {
+	switch t {
+	case EntityURL:
+		return "<URL>"
+	case EntityEmail:
+		return "<EMAIL>"
+	case EntityMention:
+		return "<MENTION>"
+	case EntityHashtag:
+		return "<HASHTAG>"
+	case EntityCurrency:
+		return "<MONEY>"
+	case EntityVersion:
+		return "<VERSION>"
+	case EntityNumber:
+		return "<NUM>"
+	default:
+		return "<UNK>"
+	}
+}
+
+// Specifies how a recognized entity is emitted by MakeEntityTokenizer().
+type EntityMode int
+
+const (
+	// The entity is not recognized, and is tokenized like any other text.
+	EntitySplit EntityMode = iota
+
+	// The entity is kept as a single token.
+	EntityKeep
+
+	// The entity is replaced by a normalization placeholder such as "<URL>".
+	EntityReplace
+)
+
+// Options for MakeEntityTokenizer().
+type EntityTokenizerOptions struct {
+	// Modes[t] -> how entities of type t are handled.  Entity types that are
+	// not present in this map are tokenized like any other text.
+	Modes map[EntityType]EntityMode
+
+	// Placeholders[t] -> the token to emit for entities of type t that are in
+	// EntityReplace mode.  Defaults to EntityType.Placeholder().
+	Placeholders map[EntityType]string
+}
+
+// Returns options that keep URLs, email addresses, @mentions, #hashtags,
+// currency amounts and version numbers as single tokens.
+func WebEntityTokenizerOptions() EntityTokenizerOptions {
+	return EntityTokenizerOptions{
+		Modes: map[EntityType]EntityMode{
+			EntityURL:      EntityKeep,
+			EntityEmail:    EntityKeep,
+			EntityMention:  EntityKeep,
+			EntityHashtag:  EntityKeep,
+			EntityCurrency: EntityKeep,
+			EntityVersion:  EntityKeep,
+		},
+	}
+}
+
+// Creates a social/web-aware Tokenize function.  Entities enabled in opts are
+// either kept as single tokens or replaced with placeholders, and all remaining
+// text is tokenized by MakeDefaultTokenizer().
+//
+// Kept URLs are emitted verbatim (minus any trailing punctuation), since their
+// paths are case-sensitive.  All other kept entities are case-folded.
+func MakeEntityTokenizer(opts EntityTokenizerOptions) Tokenize {
+	defaultTokenize := MakeDefaultTokenizer()
+
+	// Build a single regex with one named group per enabled entity type.
+	groupTypes := []EntityType{}
+	groupPatterns := []string{}
+	for _, entity := range entityPatterns {
+		mode := opts.Modes[entity.entityType]
+		if mode == EntityKeep || mode == EntityReplace {
+			groupTypes = append(groupTypes, entity.entityType)
+			groupPatterns = append(groupPatterns, "("+entity.pattern+")")
+		}
+	}
+
+	if len(groupPatterns) == 0 {
+		return defaultTokenize
+	}
+	re := regexp.MustCompile(strings.Join(groupPatterns, "|"))
+
+	return func(text string) []string {
+		tokens := make([]string, 0, 16)
+		textStart := 0
+
+		for _, match := range re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := match[0], match[1]
+			entityType := matchedEntityType(match, groupTypes)
+
+			// @mentions and #hashtags must not be glued to a preceding word.
+			if entityType == EntityMention || entityType == EntityHashtag {
+				if prev, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && (unicode.IsLetter(prev) || unicode.IsNumber(prev)) {
+					continue
+				}
+			}
+
+			entity := text[start:end]
+			if entityType == EntityURL {
+				entity = strings.TrimRight(entity, `.,;:!?)]}'"`)
+				end = start + len(entity)
+			}
+
+			tokens = append(tokens, defaultTokenize(text[textStart:start])...)
+			textStart = end
+
+			if opts.Modes[entityType] == EntityReplace {
+				placeholder, found := opts.Placeholders[entityType]
+				if !found {
+					placeholder = entityType.Placeholder()
+				}
+				tokens = append(tokens, placeholder)
+			} else if entityType == EntityURL {
+				tokens = append(tokens, entity)
+			} else {
+				tokens = append(tokens, strings.ToLower(entity))
+			}
+		}
+
+		return append(tokens, defaultTokenize(text[textStart:])...)
+	}
+}
+
+// Returns the entity type of the regex group that produced the specified match.
+func matchedEntityType(match []int, groupTypes []EntityType) EntityType {
+	for i, entityType := range groupTypes {
+		if match[2*(i+1)] >= 0 {
+			return entityType
+		}
+	}
+	panic("regex match did not include any entity group")
+}
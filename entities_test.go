@@ -0,0 +1,77 @@
+package gosim
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func ExampleMakeEntityTokenizer() {
+	tokenize := MakeEntityTokenizer(WebEntityTokenizerOptions())
+	tokens := tokenize("Thanks @GoLang! Go 1.15.2 is out: https://golang.org/doc/go1.15 #GoLang")
+
+	for i, token := range tokens {
+		fmt.Printf("token %v: %v\n", i, token)
+	}
+	// Output:
+	// token 0: thanks
+	// token 1: @golang
+	// token 2: go
+	// token 3: 1.15.2
+	// token 4: is
+	// token 5: out
+	// token 6: https://golang.org/doc/go1.15
+	// token 7: #golang
+}
+
+func TestMakeEntityTokenizer_keep(t *testing.T) {
+	tokenize := MakeEntityTokenizer(WebEntityTokenizerOptions())
+
+	assert.Equal(t,
+		[]string{"mail", "john.smith@example.com", "or", "visit", "www.Example.com/About"},
+		tokenize("Mail John.Smith@Example.com or visit www.Example.com/About."),
+	)
+	assert.Equal(t, []string{"it", "costs", "$1,000.50", "or", "€20"}, tokenize("It costs $1,000.50 or €20!"))
+	assert.Equal(t, []string{"upgrade", "to", "v2.0.1"}, tokenize("Upgrade to v2.0.1"))
+
+	// Plain numbers are not enabled by WebEntityTokenizerOptions()
+	assert.Equal(t, []string{"42", "is", "the", "answer"}, tokenize("42 is the answer"))
+}
+
+func TestMakeEntityTokenizer_replace(t *testing.T) {
+	tokenize := MakeEntityTokenizer(EntityTokenizerOptions{
+		Modes: map[EntityType]EntityMode{
+			EntityURL:    EntityReplace,
+			EntityNumber: EntityReplace,
+			EntityEmail:  EntityReplace,
+		},
+		Placeholders: map[EntityType]string{EntityEmail: "__email__"},
+	})
+
+	assert.Equal(t,
+		[]string{"see", "<URL>", "or", "call", "<NUM>", "times", "or", "mail", "__email__"},
+		tokenize("See http://example.com/x?y=1, or call 3 times, or mail a@b.io"),
+	)
+
+	// Numbers that are part of a word are not entities.
+	assert.Equal(t, []string{"the", "2nd", "mp3", "player"}, tokenize("the 2nd mp3 player"))
+}
+
+func TestMakeEntityTokenizer_mentionsAndHashtags(t *testing.T) {
+	tokenize := MakeEntityTokenizer(EntityTokenizerOptions{
+		Modes: map[EntityType]EntityMode{
+			EntityMention: EntityReplace,
+			EntityHashtag: EntityKeep,
+		},
+	})
+
+	assert.Equal(t, []string{"<MENTION>", "loves", "#nlp"}, tokenize("@Bob loves #NLP"))
+
+	// '#' and '@' glued to a word do not start an entity, and "#1" is not a hashtag.
+	assert.Equal(t, []string{"sharp", "foo", "bar"}, tokenize("C#sharp foo@bar #1"))
+}
+
+func TestMakeEntityTokenizer_noEntities(t *testing.T) {
+	tokenize := MakeEntityTokenizer(EntityTokenizerOptions{})
+	assert.Equal(t, MakeDefaultTokenizer()("Visit www.example.com"), tokenize("Visit www.example.com"))
+}
@@ -0,0 +1,170 @@
+package gosim
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// The maximum n-gram length used by language profiles.
+	maxNgramLength = 5
+
+	// The number of top-ranked n-grams kept in a language profile.
+	languageProfileSize = 400
+)
+
+// A ranked guess returned by LanguageIdentifier.Identify().
+type LanguageGuess struct {
+	// ISO 639-1 language code (e.g. "en").
+	Language string
+
+	// A score in the range [0.0..1.0], where 1.0 means the text's n-gram
+	// ranking matched the language profile perfectly.
+	Confidence float64
+}
+
+// LanguageIdentifier guesses the language of a text by comparing its ranked
+// character n-grams against the n-gram profiles of known languages, using the
+// "out-of-place" measure described by Cavnar & Trenkle (1994).
+//
+// See https://www.let.rug.nl/~vannoord/TextCat/textcat.pdf
+type LanguageIdentifier struct {
+	// profiles[lang][ngram] -> rank of ngram within the profile of language lang.
+	profiles map[string]map[string]int
+}
+
+// Creates a LanguageIdentifier with the bundled profiles for English (en),
+// French (fr), German (de), Spanish (es), Italian (it), Portuguese (pt) and
+// Dutch (nl).
+func NewLanguageIdentifier() *LanguageIdentifier {
+	li := NewEmptyLanguageIdentifier()
+	for lang, sample := range bundledLanguageSamples {
+		li.AddProfile(lang, sample)
+	}
+	return li
+}
+
+// Creates a LanguageIdentifier without any language profiles.
+func NewEmptyLanguageIdentifier() *LanguageIdentifier {
+	return &LanguageIdentifier{
+		profiles: make(map[string]map[string]int),
+	}
+}
+
+// Builds the profile for the specified language from a sample text written in
+// that language, replacing any existing profile for lang.  A few hundred words
+// of sample text are usually enough.
+func (me *LanguageIdentifier) AddProfile(lang string, sampleText string) {
+	me.profiles[lang] = rankNgrams(sampleText, languageProfileSize)
+}
+
+// Returns the languages that this identifier knows about, in sorted order.
+func (me *LanguageIdentifier) Languages() []string {
+	langs := make([]string, 0, len(me.profiles))
+	for lang := range me.profiles {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Returns a guess for each known language, sorted by decreasing confidence.
+// Returns an empty list if text does not contain any letters.
+func (me *LanguageIdentifier) Identify(text string) []LanguageGuess {
+	textProfile := rankNgrams(text, languageProfileSize)
+	if len(textProfile) == 0 {
+		return []LanguageGuess{}
+	}
+
+	maxDistance := len(textProfile) * languageProfileSize
+
+	guesses := make([]LanguageGuess, 0, len(me.profiles))
+	for lang, profile := range me.profiles {
+		distance := 0
+		for ngram, rank := range textProfile {
+			profileRank, found := profile[ngram]
+			if !found {
+				distance += languageProfileSize
+			} else if profileRank > rank {
+				distance += profileRank - rank
+			} else {
+				distance += rank - profileRank
+			}
+		}
+
+		guesses = append(guesses, LanguageGuess{
+			Language:   lang,
+			Confidence: 1.0 - float64(distance)/float64(maxDistance),
+		})
+	}
+
+	sort.Slice(guesses, func(i, j int) bool {
+		if guesses[i].Confidence != guesses[j].Confidence {
+			return guesses[i].Confidence > guesses[j].Confidence
+		}
+		return guesses[i].Language < guesses[j].Language
+	})
+	return guesses
+}
+
+// Creates a Tokenize function that identifies the language of each text and
+// then delegates to pipelines[lang], so that each language can have its own
+// stopword list, stemmer, etc.  Texts whose most likely language has no
+// pipeline (or that contain no letters) are tokenized by fallback.
+func MakeLanguageTokenizer(li *LanguageIdentifier, pipelines map[string]Tokenize, fallback Tokenize) Tokenize {
+	return func(text string) []string {
+		guesses := li.Identify(text)
+		if len(guesses) > 0 {
+			if tokenize, found := pipelines[guesses[0].Language]; found {
+				return tokenize(text)
+			}
+		}
+		return fallback(text)
+	}
+}
+
+// Counts the character n-grams (of length 1..maxNgramLength) of each word in
+// text, and returns a mapping of the top n n-grams to their rank (0 = most
+// frequent).  Words are padded with '_' so that n-grams capture word
+// boundaries.
+func rankNgrams(text string, n int) map[string]int {
+	counts := make(map[string]int, 1000)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c)
+	})
+
+	for _, word := range words {
+		padded := []rune("_" + word + "_")
+		for i := range padded {
+			for length := 1; length <= maxNgramLength && i+length <= len(padded); length++ {
+				ngram := string(padded[i : i+length])
+				if ngram != "_" {
+					counts[ngram]++
+				}
+			}
+		}
+	}
+
+	ngrams := make([]string, 0, len(counts))
+	for ngram := range counts {
+		ngrams = append(ngrams, ngram)
+	}
+	sort.Slice(ngrams, func(i, j int) bool {
+		if counts[ngrams[i]] != counts[ngrams[j]] {
+			return counts[ngrams[i]] > counts[ngrams[j]]
+		}
+		return ngrams[i] < ngrams[j]
+	})
+
+	if len(ngrams) > n {
+		ngrams = ngrams[:n]
+	}
+
+	ranks := make(map[string]int, len(ngrams))
+	for rank, ngram := range ngrams {
+		ranks[ngram] = rank
+	}
+	return ranks
+}
@@ -0,0 +1,127 @@
+package gosim
+
+// Sample texts from which the bundled language profiles are built (see
+// NewLanguageIdentifier()).
+var bundledLanguageSamples = map[string]string{
+	"en": `
+		All human beings are born free and equal in dignity and rights. They are
+		endowed with reason and conscience and should act towards one another in
+		a spirit of brotherhood. Everyone is entitled to all the rights and
+		freedoms set forth in this declaration, without distinction of any kind,
+		such as race, colour, sex, language, religion, political or other opinion,
+		national or social origin, property, birth or other status. Everyone has
+		the right to life, liberty and security of person. No one shall be held in
+		slavery or servitude. The weather was cold that morning, so we stayed at
+		home and read the newspaper while the children were playing with their
+		friends in the garden. What would you like to have for dinner tonight? I
+		think that we should go to the market before it closes, because there is
+		nothing left in the kitchen and everybody will be hungry when they come
+		back from school. This is the best book that I have read in a long time,
+		and I would recommend it to anyone who enjoys a good story.`,
+
+	"fr": `
+		Tous les êtres humains naissent libres et égaux en dignité et en droits.
+		Ils sont doués de raison et de conscience et doivent agir les uns envers
+		les autres dans un esprit de fraternité. Chacun peut se prévaloir de tous
+		les droits et de toutes les libertés proclamés dans la présente
+		déclaration, sans distinction aucune, notamment de race, de couleur, de
+		sexe, de langue, de religion, d'opinion politique ou de toute autre
+		opinion, d'origine nationale ou sociale, de fortune, de naissance ou de
+		toute autre situation. Tout individu a droit à la vie, à la liberté et à
+		la sûreté de sa personne. Nul ne sera tenu en esclavage ni en servitude.
+		Il faisait froid ce matin, alors nous sommes restés à la maison pour lire
+		le journal pendant que les enfants jouaient avec leurs amis dans le
+		jardin. Qu'est-ce que tu veux manger ce soir? Je pense que nous devrions
+		aller au marché avant qu'il ne ferme, parce qu'il n'y a plus rien dans la
+		cuisine et tout le monde aura faim en rentrant de l'école. C'est le
+		meilleur livre que j'ai lu depuis longtemps.`,
+
+	"de": `
+		Alle Menschen sind frei und gleich an Würde und Rechten geboren. Sie sind
+		mit Vernunft und Gewissen begabt und sollen einander im Geist der
+		Brüderlichkeit begegnen. Jeder hat Anspruch auf die in dieser Erklärung
+		verkündeten Rechte und Freiheiten ohne irgendeinen Unterschied, etwa nach
+		Rasse, Hautfarbe, Geschlecht, Sprache, Religion, politischer oder
+		sonstiger Überzeugung, nationaler oder sozialer Herkunft, Vermögen,
+		Geburt oder sonstigem Stand. Jeder hat das Recht auf Leben, Freiheit und
+		Sicherheit der Person. Niemand darf in Sklaverei oder Leibeigenschaft
+		gehalten werden. Heute Morgen war es sehr kalt, deshalb sind wir zu Hause
+		geblieben und haben die Zeitung gelesen, während die Kinder mit ihren
+		Freunden im Garten gespielt haben. Was möchtest du heute Abend essen? Ich
+		glaube, dass wir noch zum Markt gehen sollten, bevor er schließt, weil
+		nichts mehr in der Küche ist und alle hungrig sein werden, wenn sie aus
+		der Schule kommen. Das ist das beste Buch, das ich seit langer Zeit
+		gelesen habe.`,
+
+	"es": `
+		Todos los seres humanos nacen libres e iguales en dignidad y derechos y,
+		dotados como están de razón y conciencia, deben comportarse
+		fraternalmente los unos con los otros. Toda persona tiene todos los
+		derechos y libertades proclamados en esta declaración, sin distinción
+		alguna de raza, color, sexo, idioma, religión, opinión política o de
+		cualquier otra índole, origen nacional o social, posición económica,
+		nacimiento o cualquier otra condición. Todo individuo tiene derecho a la
+		vida, a la libertad y a la seguridad de su persona. Nadie estará
+		sometido a esclavitud ni a servidumbre. Esta mañana hacía mucho frío, así
+		que nos quedamos en casa y leímos el periódico mientras los niños jugaban
+		con sus amigos en el jardín. ¿Qué quieres cenar esta noche? Creo que
+		deberíamos ir al mercado antes de que cierre, porque no queda nada en la
+		cocina y todos tendrán hambre cuando vuelvan de la escuela. Este es el
+		mejor libro que he leído en mucho tiempo.`,
+
+	"it": `
+		Tutti gli esseri umani nascono liberi ed eguali in dignità e diritti.
+		Essi sono dotati di ragione e di coscienza e devono agire gli uni verso
+		gli altri in spirito di fratellanza. Ad ogni individuo spettano tutti i
+		diritti e tutte le libertà enunciate nella presente dichiarazione, senza
+		distinzione alcuna, per ragioni di razza, di colore, di sesso, di lingua,
+		di religione, di opinione politica o di altro genere, di origine
+		nazionale o sociale, di ricchezza, di nascita o di altra condizione. Ogni
+		individuo ha diritto alla vita, alla libertà ed alla sicurezza della
+		propria persona. Nessun individuo potrà essere tenuto in stato di
+		schiavitù o di servitù. Questa mattina faceva molto freddo, quindi siamo
+		rimasti a casa a leggere il giornale mentre i bambini giocavano con i
+		loro amici in giardino. Che cosa vuoi mangiare stasera? Penso che
+		dovremmo andare al mercato prima che chiuda, perché non è rimasto niente
+		in cucina e tutti avranno fame quando tornano da scuola. Questo è il
+		libro più bello che ho letto da molto tempo.`,
+
+	"pt": `
+		Todos os seres humanos nascem livres e iguais em dignidade e em direitos.
+		Dotados de razão e de consciência, devem agir uns para com os outros em
+		espírito de fraternidade. Todos os seres humanos podem invocar os
+		direitos e as liberdades proclamados na presente declaração, sem
+		distinção alguma, nomeadamente de raça, de cor, de sexo, de língua, de
+		religião, de opinião política ou outra, de origem nacional ou social, de
+		fortuna, de nascimento ou de qualquer outra situação. Todo o indivíduo
+		tem direito à vida, à liberdade e à segurança pessoal. Ninguém será
+		mantido em escravatura ou em servidão. Esta manhã estava muito frio,
+		então ficámos em casa a ler o jornal enquanto as crianças brincavam com
+		os seus amigos no jardim. O que é que queres jantar hoje à noite? Acho
+		que devíamos ir ao mercado antes que feche, porque não há mais nada na
+		cozinha e toda a gente vai ter fome quando voltar da escola. Este é o
+		melhor livro que eu li em muito tempo, e não consigo parar de pensar
+		nele. A educação deve visar à plena expansão da personalidade humana e ao
+		reforço dos direitos do homem e das liberdades fundamentais. Os pais têm
+		prioridade de direito na escolha do género de educação a dar aos filhos.
+		A minha irmã também trabalha numa pequena loja perto da estação, onde
+		vende pão, queijo e vinho aos vizinhos. Não sei se ela vai conseguir vir
+		connosco amanhã, mas espero que sim, porque a viagem até à praia é longa
+		e a companhia dela é sempre agradável.`,
+
+	"nl": `
+		Alle mensen worden vrij en gelijk in waardigheid en rechten geboren. Zij
+		zijn begiftigd met verstand en geweten, en behoren zich jegens elkander
+		in een geest van broederschap te gedragen. Een ieder heeft aanspraak op
+		alle rechten en vrijheden, in deze verklaring opgesomd, zonder enig
+		onderscheid van welke aard ook, zoals ras, kleur, geslacht, taal,
+		godsdienst, politieke of andere overtuiging, nationale of maatschappelijke
+		afkomst, eigendom, geboorte of andere status. Een ieder heeft het recht
+		op leven, vrijheid en onschendbaarheid van zijn persoon. Niemand zal in
+		slavernij of dienstbaarheid gehouden worden. Het was vanochtend erg koud,
+		dus zijn we thuis gebleven en hebben we de krant gelezen terwijl de
+		kinderen met hun vrienden in de tuin speelden. Wat wil je vanavond eten?
+		Ik denk dat we nog naar de markt moeten gaan voordat die dichtgaat, want
+		er is niets meer in de keuken en iedereen zal honger hebben als ze uit
+		school komen. Dit is het beste boek dat ik in lange tijd heb gelezen.`,
+}
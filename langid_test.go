@@ -0,0 +1,83 @@
+package gosim
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func ExampleLanguageIdentifier() {
+	li := NewLanguageIdentifier()
+	guesses := li.Identify("Der schnelle braune Fuchs springt über den faulen Hund.")
+	fmt.Printf("Most likely language: %v\n", guesses[0].Language)
+	// Output:
+	// Most likely language: de
+}
+
+func TestLanguageIdentifier_Identify(t *testing.T) {
+	li := NewLanguageIdentifier()
+	assert.Equal(t, []string{"de", "en", "es", "fr", "it", "nl", "pt"}, li.Languages())
+
+	texts := map[string]string{
+		"en": "The quick brown fox jumps over the lazy dog while the farmer watches.",
+		"fr": "Le renard brun rapide saute par-dessus le chien paresseux pendant que le fermier regarde.",
+		"de": "Der schnelle braune Fuchs springt über den faulen Hund, während der Bauer zuschaut.",
+		"es": "El rápido zorro marrón salta sobre el perro perezoso mientras el granjero mira.",
+		"it": "La veloce volpe marrone salta sopra il cane pigro mentre il contadino guarda.",
+		"pt": "A rápida raposa castanha salta sobre o cão preguiçoso enquanto o agricultor observa.",
+		"nl": "De snelle bruine vos springt over de luie hond terwijl de boer toekijkt.",
+	}
+
+	for expectedLang, text := range texts {
+		guesses := li.Identify(text)
+		if assert.Equal(t, 7, len(guesses)) {
+			assert.Equal(t, expectedLang, guesses[0].Language, text)
+
+			// Verify guesses are ranked by decreasing confidence
+			for i := 0; i < len(guesses)-1; i++ {
+				assert.True(t, guesses[i].Confidence >= guesses[i+1].Confidence)
+			}
+			assert.True(t, guesses[0].Confidence > 0.0 && guesses[0].Confidence <= 1.0)
+		}
+	}
+}
+
+func TestLanguageIdentifier_Identify_noLetters(t *testing.T) {
+	li := NewLanguageIdentifier()
+	assert.Equal(t, []LanguageGuess{}, li.Identify(""))
+	assert.Equal(t, []LanguageGuess{}, li.Identify("123 -- 456 !!"))
+}
+
+func TestLanguageIdentifier_AddProfile(t *testing.T) {
+	li := NewEmptyLanguageIdentifier()
+	assert.Equal(t, []LanguageGuess{}, li.Identify("hello"))
+
+	li.AddProfile("aaa", strings.Repeat("aaa aa a ", 50))
+	li.AddProfile("bbb", strings.Repeat("bbb bb b ", 50))
+
+	guesses := li.Identify("bb bbb")
+	assert.Equal(t, "bbb", guesses[0].Language)
+	assert.True(t, guesses[0].Confidence > 0.5)
+	assert.Equal(t, "aaa", guesses[1].Language)
+	assert.Equal(t, 0.0, guesses[1].Confidence)
+}
+
+func TestMakeLanguageTokenizer(t *testing.T) {
+	li := NewLanguageIdentifier()
+	tokenize := MakeDefaultTokenizer()
+
+	pipelines := map[string]Tokenize{
+		"en": func(text string) []string {
+			return append([]string{"en:"}, tokenize(text)...)
+		},
+	}
+	fallback := func(text string) []string {
+		return append([]string{"other:"}, tokenize(text)...)
+	}
+
+	langTokenize := MakeLanguageTokenizer(li, pipelines, fallback)
+	assert.Equal(t, []string{"en:", "the", "children", "are", "playing"}, langTokenize("The children are playing"))
+	assert.Equal(t, []string{"other:", "los", "niños", "están", "jugando"}, langTokenize("Los niños están jugando"))
+	assert.Equal(t, []string{"other:", "42"}, langTokenize("42"))
+}
@@ -0,0 +1,358 @@
+// Package bm25 provides an implementation of the Okapi BM25 ranking function,
+// along with its BM25+ and BM25L variants.
+//
+// See https://en.wikipedia.org/wiki/Okapi_BM25
+package bm25
+
+// NOTES:
+// - Lv & Zhai, "Lower-Bounding Term Frequency Normalization" (BM25+), CIKM 2011
+// - Lv & Zhai, "When Documents Are Very Long, BM25 Fails!" (BM25L), SIGIR 2011
+// - The IDF formulas and default parameters follow the rank_bm25 Python package.
+// - Unlike rank_bm25, which adds the Delta based credit of every query term to
+//   every document under BM25+ and BM25L, only the query terms that a document
+//   contains contribute to its score, and documents without any query term are
+//   not ranked.
+//
+
+import (
+	"errors"
+	"github.com/cet001/gosim/models/tfidf"
+	"github.com/cet001/mathext/vectors"
+	"math"
+	"sort"
+)
+
+var (
+	// Returned by AddDoc() when the corpus already contains a document with the
+	// same Id.
+	ErrDuplicateDocId = errors.New("bm25: duplicate document Id")
+
+	// Returned by queries when documents have been added since the last call
+	// to Train().
+	ErrNotTrained = errors.New("bm25: corpus stats need to be recalculated; call Train()")
+
+	// Returned by queries that have no terms.
+	ErrEmptyQuery = errors.New("bm25: empty query")
+)
+
+// A document Id and its score.  This is the same type as tfidf.ScoredItem, so
+// that results of both models can be handled alike.
+type ScoredItem = tfidf.ScoredItem
+
+// Selects the BM25 ranking function.
+type Variant int
+
+const (
+	// The original Okapi BM25.
+	Okapi Variant = iota
+
+	// BM25+, which adds Delta to the normalized term frequency of every term
+	// that a document contains, so that long documents are not penalized
+	// below documents that lack the term altogether.
+	Plus
+
+	// BM25L, which shifts the length normalized term frequency by Delta to
+	// avoid overly penalizing long documents.
+	L
+)
+
+func (v Variant) String() string {
+	switch v {
+	case Okapi:
+		return "BM25"
+	case Plus:
+		return "BM25+"
+	case L:
+		return "BM25L"
+	default:
+		return "Unknown"
+	}
+}
+
+// BM25 model.
+type BM25 struct {
+	// The ranking function.
+	Variant Variant
+
+	// Controls term frequency saturation: the larger K1, the more repeated
+	// occurrences of a term keep raising the score.
+	K1 float64
+
+	// Controls document length normalization, from 0 (none) to 1 (full).
+	B float64
+
+	// The term frequency lower bound of BM25+, or the term frequency shift of
+	// BM25L.  Not used by Okapi.  0 means the default of the variant: 1 for
+	// BM25+ and 0.5 for BM25L.
+	Delta float64
+
+	// Okapi only: terms that are present in more than half of the documents
+	// have a negative IDF, which is replaced by Epsilon times the average IDF
+	// of the vocabulary.
+	Epsilon float64
+
+	// The documents within this corpus.
+	docs []document
+
+	// docIndex[id] -> the index within docs of the document with the specified
+	// Id.
+	docIndex map[int]int
+
+	// idf[t] -> the inverse document frequency of term t.
+	idf map[int]float64
+
+	// The average document length, in terms.
+	avgDocLen float64
+
+	// index[t] -> the documents that contain term t, in increasing docIdx order.
+	index map[int][]posting
+
+	// Whenever new documents are added to this corpus, the corpus stats need to
+	// be recalculated (via Train()).  This flag keeps track of this state.
+	needsRecalc bool
+}
+
+type document struct {
+	id int
+
+	// The term count vector of the document.
+	tf vectors.SparseVector
+
+	// The number of terms in the document (the sum of the term counts).
+	length float64
+}
+
+// The weight of a term within a document, before it is multiplied by the
+// term's IDF.
+type posting struct {
+	docIdx int
+	weight float64
+}
+
+// Creates an empty model that uses the specified ranking function, with the
+// default parameters of the rank_bm25 package: K1 = 1.5, B = 0.75, Epsilon =
+// 0.25, and Delta = 1 for BM25+ or 0.5 for BM25L (see Delta).
+func NewBM25(variant Variant) *BM25 {
+	return &BM25{
+		Variant:     variant,
+		K1:          1.5,
+		B:           0.75,
+		Epsilon:     0.25,
+		docs:        make([]document, 0, 1000),
+		docIndex:    make(map[int]int),
+		needsRecalc: true,
+	}
+}
+
+// Adds a document to this corpus.  doc holds the raw term counts of the
+// document (see gosim.Dictionary.VectorizeCounts()).  Returns
+// ErrDuplicateDocId if the corpus already contains a document with the same Id.
+//
+// The model needs to be retrained before it can be queried again.
+func (me *BM25) AddDoc(docId int, doc vectors.SparseVector) error {
+	if _, found := me.docIndex[docId]; found {
+		return ErrDuplicateDocId
+	}
+
+	length := 0.0
+	for _, term := range doc {
+		length += term.Value
+	}
+
+	me.docIndex[docId] = len(me.docs)
+	me.docs = append(me.docs, document{id: docId, tf: doc, length: length})
+	me.needsRecalc = true
+	return nil
+}
+
+// Returns the number of documents in this corpus.
+func (me *BM25) DocCount() int {
+	return len(me.docs)
+}
+
+// Calculates the IDF values and the average document length, and builds the
+// inverted index of the corpus.
+func (me *BM25) Train() {
+	df := make(map[int]int, 10000)
+	totalLength := 0.0
+	for i := range me.docs {
+		for _, term := range me.docs[i].tf {
+			df[term.Id]++
+		}
+		totalLength += me.docs[i].length
+	}
+
+	me.avgDocLen = 0
+	if len(me.docs) > 0 {
+		me.avgDocLen = totalLength / float64(len(me.docs))
+	}
+	me.idf = calcIDF(df, len(me.docs), me.Variant, me.Epsilon)
+
+	me.index = make(map[int][]posting, len(df))
+	for docIdx := range me.docs {
+		doc := &me.docs[docIdx]
+		for _, term := range doc.tf {
+			me.index[term.Id] = append(me.index[term.Id], posting{
+				docIdx: docIdx,
+				weight: me.termWeight(term.Value, doc.length),
+			})
+		}
+	}
+
+	me.needsRecalc = false
+}
+
+// Calculates the IDF of each term, given its document frequency.
+func calcIDF(df map[int]int, numDocs int, variant Variant, epsilon float64) map[int]float64 {
+	n := float64(numDocs)
+	idf := make(map[int]float64, len(df))
+
+	switch variant {
+	case Plus:
+		for termId, freq := range df {
+			idf[termId] = math.Log((n + 1) / float64(freq))
+		}
+	case L:
+		for termId, freq := range df {
+			idf[termId] = math.Log(n+1) - math.Log(float64(freq)+0.5)
+		}
+	default:
+		idfSum := 0.0
+		negativeIdfs := []int{}
+		for termId, freq := range df {
+			value := math.Log(n-float64(freq)+0.5) - math.Log(float64(freq)+0.5)
+			idf[termId] = value
+			idfSum += value
+			if value < 0 {
+				negativeIdfs = append(negativeIdfs, termId)
+			}
+		}
+
+		if len(negativeIdfs) > 0 {
+			floor := epsilon * idfSum / float64(len(idf))
+			for _, termId := range negativeIdfs {
+				idf[termId] = floor
+			}
+		}
+	}
+
+	return idf
+}
+
+// Returns Delta, or the default Delta of the variant if Delta is 0.
+func (me *BM25) delta() float64 {
+	if me.Delta != 0 {
+		return me.Delta
+	}
+	switch me.Variant {
+	case Plus:
+		return 1
+	case L:
+		return 0.5
+	default:
+		return 0
+	}
+}
+
+// Calculates the weight (before multiplying by the IDF) of a term that occurs
+// tf times within a document of the specified length.
+func (me *BM25) termWeight(tf, docLen float64) float64 {
+	lengthNorm := 1 - me.B
+	if me.avgDocLen > 0 {
+		lengthNorm += me.B * docLen / me.avgDocLen
+	}
+
+	delta := me.delta()
+	switch me.Variant {
+	case Plus:
+		return delta + (tf*(me.K1+1))/(me.K1*lengthNorm+tf)
+	case L:
+		ctd := tf / lengthNorm
+		return (me.K1 + 1) * (ctd + delta) / (me.K1 + ctd + delta)
+	default:
+		return (tf * (me.K1 + 1)) / (tf + me.K1*lengthNorm)
+	}
+}
+
+// Returns the documents that share at least one term with the specified query,
+// sorted by decreasing score (documents with the same score are returned in
+// the order in which they were added).  The query holds raw term counts; a
+// term that occurs more than once in the query contributes once per
+// occurrence.
+//
+// Panics if the model has not been trained.
+func (me *BM25) SimilarDocsForText(query vectors.SparseVector) []ScoredItem {
+	rankedDocs, err := me.TrySimilarDocsForText(query)
+	if err == ErrNotTrained {
+		panic(err.Error())
+	}
+	if err != nil {
+		return []ScoredItem{}
+	}
+	return rankedDocs
+}
+
+// Does what SimilarDocsForText() does, but returns an error instead of
+// panicking: ErrNotTrained if the model has not been trained, or ErrEmptyQuery
+// if the query is empty.
+func (me *BM25) TrySimilarDocsForText(query vectors.SparseVector) ([]ScoredItem, error) {
+	if me.needsRecalc {
+		return nil, ErrNotTrained
+	}
+	if len(query) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	scores := make([]float64, len(me.docs))
+	visited := make([]bool, len(me.docs))
+	touched := []int{}
+	for _, term := range query {
+		idf := term.Value * me.idf[term.Id]
+		for _, p := range me.index[term.Id] {
+			if !visited[p.docIdx] {
+				visited[p.docIdx] = true
+				touched = append(touched, p.docIdx)
+			}
+			scores[p.docIdx] += idf * p.weight
+		}
+	}
+	sort.Ints(touched)
+
+	rankedDocs := make([]ScoredItem, len(touched))
+	for i, docIdx := range touched {
+		rankedDocs[i] = ScoredItem{Id: me.docs[docIdx].id, Score: scores[docIdx]}
+	}
+	sort.SliceStable(rankedDocs, func(i, j int) bool { return rankedDocs[i].Score > rankedDocs[j].Score })
+	return rankedDocs, nil
+}
+
+// Returns the score of the document with the specified Id for the query, and
+// false if the corpus contains no such document.  Panics if the model has not
+// been trained.
+func (me *BM25) Score(query vectors.SparseVector, docId int) (float64, bool) {
+	if me.needsRecalc {
+		panic(ErrNotTrained.Error())
+	}
+
+	docIdx, found := me.docIndex[docId]
+	if !found {
+		return 0, false
+	}
+
+	doc := &me.docs[docIdx]
+	score := 0.0
+	i, j := 0, 0
+	for i < len(query) && j < len(doc.tf) {
+		switch {
+		case query[i].Id < doc.tf[j].Id:
+			i++
+		case query[i].Id > doc.tf[j].Id:
+			j++
+		default:
+			score += query[i].Value * me.idf[query[i].Id] * me.termWeight(doc.tf[j].Value, doc.length)
+			i++
+			j++
+		}
+	}
+	return score, true
+}
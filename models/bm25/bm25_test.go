@@ -0,0 +1,236 @@
+package bm25
+
+import (
+	"fmt"
+	"github.com/cet001/gosim"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// The example from the rank_bm25 README, whose BM25Okapi scores are [0,
+// 0.93729472, 0].
+func TestBM25_SimilarDocsForText_rankBM25Example(t *testing.T) {
+	model, dict := newTestModel(Okapi, []string{
+		"Hello there good man!",
+		"It is quite windy in London",
+		"How is the weather today?",
+	})
+
+	items := model.SimilarDocsForText(dict.VectorizeCounts(strings.Split("windy London", " ")))
+	assert.Equal(t, 1, len(items))
+	assert.Equal(t, 1, items[0].Id)
+	assert.InDelta(t, 0.93729472, items[0].Score, 1e-8)
+}
+
+// Verifies the scores of each variant against the scores returned by the
+// get_scores() method of rank_bm25 0.2.2 (BM25Okapi, BM25Plus and BM25L, with
+// their default parameters, and documents tokenized with split(" ")).
+//
+// This package deliberately deviates from rank_bm25 for documents that lack
+// some of the query terms: BM25Plus and BM25L add the Delta based credit of a
+// query term to every document, including those that do not contain it, while
+// here only the terms that a document contains contribute to its score, and
+// documents that contain no query term are not returned.  The reference score
+// of document 5, which lacks "fox", is therefore rank_bm25's score for the
+// query without "fox".
+func TestBM25_SimilarDocsForText_referenceScores(t *testing.T) {
+	corpus := []string{
+		"the quick brown fox jumps over the lazy dog",          // docId=0
+		"the lazy dog sleeps all day",                          // docId=1
+		"a quick brown dog outpaces a quick fox",               // docId=2
+		"foxes and dogs are not the same",                      // docId=3
+		"the brown bear eats honey all day long in the forest", // docId=4
+		"quick thinking saves the day",                         // docId=5
+		"a bird sings in the morning",                          // docId=6
+	}
+	testCases := []struct {
+		variant  Variant
+		expected []ScoredItem
+
+		// rank_bm25's scores of document 5 for the full query and for "fox"
+		// alone.
+		rankBM25Doc5, rankBM25Doc5Fox float64
+	}{
+		{
+			Okapi,
+			[]ScoredItem{{Id: 2, Score: 1.462793578281}, {Id: 0, Score: 1.178867133980}, {Id: 5, Score: 0.589328084357}},
+			0.589328084357, 0,
+		},
+		{
+			Plus,
+			[]ScoredItem{{Id: 2, Score: 7.422617437182}, {Id: 0, Score: 6.404907197107}, {Id: 5, Score: 4.261686517595}},
+			5.647980878715, 1.386294361120,
+		},
+		{
+			L,
+			[]ScoredItem{{Id: 2, Score: 3.966925696343}, {Id: 0, Score: 3.352256752186}, {Id: 5, Score: 2.268615049975}},
+			2.995584306103, 0.726969256129,
+		},
+	}
+
+	for _, tc := range testCases {
+		model, dict := newTestModel(tc.variant, corpus)
+		// "quick" is counted twice.
+		query := dict.VectorizeCounts(strings.Split("quick fox quick", " "))
+
+		items := model.SimilarDocsForText(query)
+		assert.Equal(t, len(tc.expected), len(items), tc.variant.String())
+		for i := range tc.expected {
+			assert.Equal(t, tc.expected[i].Id, items[i].Id, tc.variant.String())
+			assert.InDelta(t, tc.expected[i].Score, items[i].Score, 1e-11, tc.variant.String())
+
+			score, found := model.Score(query, items[i].Id)
+			assert.True(t, found)
+			assert.Equal(t, items[i].Score, score, tc.variant.String())
+		}
+
+		// The difference with rank_bm25 is the credit for the missing term.
+		assert.InDelta(t, tc.rankBM25Doc5-tc.rankBM25Doc5Fox, items[2].Score, 1e-11, tc.variant.String())
+	}
+}
+
+func TestBM25_Score_missingTerms(t *testing.T) {
+	for _, variant := range []Variant{Okapi, Plus, L} {
+		model, dict := newTestModel(variant, []string{"apple banana", "apple cherry", "durian"})
+
+		// Only the terms that a document contains contribute to its score.
+		score, found := model.Score(dict.VectorizeCounts([]string{"banana"}), 1)
+		assert.True(t, found)
+		assert.Equal(t, 0.0, score, variant.String())
+
+		both, _ := model.Score(dict.VectorizeCounts([]string{"apple", "banana"}), 0)
+		apple, _ := model.Score(dict.VectorizeCounts([]string{"apple"}), 0)
+		banana, _ := model.Score(dict.VectorizeCounts([]string{"banana"}), 0)
+		assert.InDelta(t, apple+banana, both, 1e-12, variant.String())
+
+		_, found = model.Score(dict.VectorizeCounts([]string{"apple"}), 99)
+		assert.False(t, found)
+	}
+}
+
+// BM25+ and BM25L give long documents that contain a term more credit than
+// Okapi does, relative to short documents.
+func TestBM25_variants_longDocuments(t *testing.T) {
+	corpus := []string{
+		"fox",
+		"fox" + strings.Repeat(" filler", 50),
+		"dog",
+		"cat",
+		"bird",
+		"eel",
+	}
+
+	ratios := map[Variant]float64{}
+	for _, variant := range []Variant{Okapi, Plus, L} {
+		model, dict := newTestModel(variant, corpus)
+		query := dict.VectorizeCounts([]string{"fox"})
+		short, _ := model.Score(query, 0)
+		long, _ := model.Score(query, 1)
+		ratios[variant] = long / short
+	}
+	assert.True(t, ratios[Plus] > ratios[Okapi])
+	assert.True(t, ratios[L] > ratios[Okapi])
+}
+
+func TestBM25_parameters(t *testing.T) {
+	assert.Equal(t, 0.0, NewBM25(Okapi).delta())
+	assert.Equal(t, 1.0, NewBM25(Plus).delta())
+	assert.Equal(t, 0.5, NewBM25(L).delta())
+
+	// The default Delta follows the variant, even if it is changed after the
+	// model has been created, while an explicit Delta is kept.
+	model := NewBM25(Plus)
+	model.Variant = L
+	assert.Equal(t, 0.5, model.delta())
+	model.Delta = 2
+	assert.Equal(t, 2.0, model.delta())
+	model.Variant = Plus
+	assert.Equal(t, 2.0, model.delta())
+
+	corpus := []string{"fox dog", "fox dog dog dog dog dog dog dog", "fox fox fox fox", "cat", "bird", "eel", "ant", "owl", "elk"}
+	model, dict := newTestModel(Okapi, corpus)
+	query := dict.VectorizeCounts([]string{"fox"})
+	short, _ := model.Score(query, 0)
+	long, _ := model.Score(query, 1)
+	assert.True(t, short > long)
+
+	// With B = 0, document length does not matter.
+	model.B = 0
+	model.Train()
+	short, _ = model.Score(query, 0)
+	long, _ = model.Score(query, 1)
+	assert.Equal(t, short, long)
+
+	// With K1 = 0, term frequency does not matter either.
+	model.K1 = 0
+	model.Train()
+	once, _ := model.Score(query, 0)
+	often, _ := model.Score(query, 2)
+	assert.Equal(t, model.idf[query[0].Id], once)
+	assert.Equal(t, once, often)
+}
+
+func TestBM25_errors(t *testing.T) {
+	model := NewBM25(Okapi)
+	doc := vectors.SparseVector{{Id: 1, Value: 2}}
+	assert.Nil(t, model.AddDoc(10, doc))
+	assert.Equal(t, ErrDuplicateDocId, model.AddDoc(10, doc))
+	assert.Equal(t, 1, model.DocCount())
+
+	_, err := model.TrySimilarDocsForText(doc)
+	assert.Equal(t, ErrNotTrained, err)
+	assert.Panics(t, func() { model.SimilarDocsForText(doc) })
+	assert.Panics(t, func() { model.Score(doc, 10) })
+
+	model.Train()
+	_, err = model.TrySimilarDocsForText(vectors.SparseVector{})
+	assert.Equal(t, ErrEmptyQuery, err)
+	assert.Equal(t, []ScoredItem{}, model.SimilarDocsForText(vectors.SparseVector{}))
+	assert.Equal(t, []ScoredItem{}, model.SimilarDocsForText(vectors.SparseVector{{Id: 2, Value: 1}}))
+
+	model.AddDoc(20, doc)
+	_, err = model.TrySimilarDocsForText(doc)
+	assert.Equal(t, ErrNotTrained, err)
+}
+
+func TestBM25_SimilarDocsForText_ties(t *testing.T) {
+	model, dict := newTestModel(Okapi, []string{"cat dog", "dog", "cat dog", "bird"})
+	items := model.SimilarDocsForText(dict.VectorizeCounts([]string{"cat"}))
+	assert.Equal(t, []int{0, 2}, []int{items[0].Id, items[1].Id})
+	assert.Equal(t, items[0].Score, items[1].Score)
+}
+
+func ExampleBM25_SimilarDocsForText() {
+	corpus := []string{
+		"the quick brown fox jumps over the lazy dog",
+		"the lazy dog sleeps all day",
+		"a quick brown dog outpaces a quick fox",
+	}
+
+	model := NewBM25(Plus)
+	dict := gosim.NewDictionary()
+	tokenize := gosim.MakeDefaultTokenizer()
+	for docId, doc := range corpus {
+		model.AddDoc(docId, dict.VectorizeCountsAndUpdate(tokenize(doc)))
+	}
+	model.Train()
+
+	for _, item := range model.SimilarDocsForText(dict.VectorizeCounts(tokenize("quick fox"))) {
+		fmt.Printf("%v %.3f\n", item.Id, item.Score)
+	}
+	// Output:
+	// 2 3.165
+	// 0 2.615
+}
+
+func newTestModel(variant Variant, corpus []string) (*BM25, *gosim.Dictionary) {
+	model := NewBM25(variant)
+	dict := gosim.NewDictionary()
+	for docId, doc := range corpus {
+		model.AddDoc(docId, dict.VectorizeCountsAndUpdate(strings.Split(doc, " ")))
+	}
+	model.Train()
+	return model, dict
+}
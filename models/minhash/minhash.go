@@ -0,0 +1,326 @@
+// Package minhash provides shingling, MinHash signatures and a banded
+// locality-sensitive hashing (LSH) index for finding near-duplicate documents
+// without comparing every pair of documents in a corpus.
+//
+// See https://en.wikipedia.org/wiki/MinHash
+package minhash
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+// 2^61 - 1, the Mersenne prime modulus used by the MinHash hash functions.
+const mersennePrime = (1 << 61) - 1
+
+// Converts a list of tokens (e.g. the output of a gosim.Tokenize function) into
+// the set of its k-token shingles.  Each shingle is represented by a 64-bit
+// hash.  A non-empty token list that is shorter than k produces a single
+// shingle.
+//
+// Returns the distinct shingle hashes in ascending order.
+func Shingles(tokens []string, k int) []uint64 {
+	if len(tokens) == 0 {
+		return []uint64{}
+	}
+	if k < 1 {
+		k = 1
+	}
+
+	numShingles := len(tokens) - k + 1
+	if numShingles < 1 {
+		numShingles = 1
+	}
+
+	shingles := make([]uint64, 0, numShingles)
+	for i := 0; i < numShingles; i++ {
+		end := i + k
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+
+		h := fnv.New64a()
+		for _, token := range tokens[i:end] {
+			h.Write([]byte(token))
+			h.Write([]byte{0}) // token separator
+		}
+		shingles = append(shingles, h.Sum64())
+	}
+
+	return uniq(shingles)
+}
+
+// Calculates the exact Jaccard similarity of two shingle sets, as returned by
+// Shingles().  Returns 0.0 if both sets are empty.
+func Jaccard(a, b []uint64) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0.0
+	}
+
+	intersection := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if a[i] < b[j] {
+			i++
+		} else if b[j] < a[i] {
+			j++
+		} else {
+			intersection++
+			i++
+			j++
+		}
+	}
+
+	return float64(intersection) / float64(len(a)+len(b)-intersection)
+}
+
+// A MinHash signature; element i is the minimum value of the i-th hash function
+// over a document's shingles.
+type Signature []uint64
+
+// Estimates the Jaccard similarity of the shingle sets from which two signatures
+// (generated by the same MinHasher) were computed.
+func EstimateJaccard(a, b Signature) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0.0
+	}
+
+	matches := 0
+	for i := range a {
+		if a[i] == b[i] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(len(a))
+}
+
+// MinHasher generates MinHash signatures using a family of universal hash
+// functions h(x) = (a*x + b) mod p.
+type MinHasher struct {
+	a []uint64
+	b []uint64
+}
+
+// Creates a MinHasher that produces signatures with numHashes elements.  Only
+// signatures generated from the same numHashes and seed are comparable.
+func NewMinHasher(numHashes int, seed int64) *MinHasher {
+	rng := rand.New(rand.NewSource(seed))
+	mh := &MinHasher{
+		a: make([]uint64, numHashes),
+		b: make([]uint64, numHashes),
+	}
+	for i := 0; i < numHashes; i++ {
+		mh.a[i] = 1 + uint64(rng.Int63n(mersennePrime-1))
+		mh.b[i] = uint64(rng.Int63n(mersennePrime))
+	}
+	return mh
+}
+
+// Returns the number of elements in the signatures produced by this MinHasher.
+func (me *MinHasher) NumHashes() int {
+	return len(me.a)
+}
+
+// Computes the MinHash signature of the specified shingle set.  An empty set
+// produces a signature whose elements are all math.MaxUint64.
+func (me *MinHasher) Signature(shingles []uint64) Signature {
+	sig := make(Signature, len(me.a))
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+
+	for _, shingle := range shingles {
+		x := shingle % mersennePrime
+		for i := range sig {
+			if h := hashMod(me.a[i], x, me.b[i]); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+
+	return sig
+}
+
+// Calculates (a*x + b) mod 2^61-1 without overflowing.
+func hashMod(a, x, b uint64) uint64 {
+	hi, lo := bits.Mul64(a, x)
+	lo, carry := bits.Add64(lo, b, 0)
+	hi += carry
+
+	_, r := bits.Div64(hi%mersennePrime, lo, mersennePrime)
+	return r
+}
+
+// A pair of candidate near-duplicate documents.
+type Pair struct {
+	Id1 int
+	Id2 int
+
+	// The estimated Jaccard similarity of the 2 documents.
+	Similarity float64
+}
+
+// LSHIndex groups MinHash signatures into buckets, one set of buckets per band
+// of rows, so that documents with a high Jaccard similarity are likely to share
+// at least one bucket.  The probability of 2 documents with similarity s
+// becoming candidates is 1 - (1 - s^rows)^bands.
+type LSHIndex struct {
+	bands int
+	rows  int
+
+	// buckets[band][bandHash] -> the Ids of the documents in that bucket.
+	buckets []map[uint64][]int
+
+	// signatures[docId] -> the signature of document docId.
+	signatures map[int]Signature
+
+	// Document Ids in insertion order.
+	docIds []int
+}
+
+// Creates an empty LSHIndex for signatures with at least bands*rows elements.
+func NewLSHIndex(bands, rows int) *LSHIndex {
+	buckets := make([]map[uint64][]int, bands)
+	for i := range buckets {
+		buckets[i] = make(map[uint64][]int)
+	}
+
+	return &LSHIndex{
+		bands:      bands,
+		rows:       rows,
+		buckets:    buckets,
+		signatures: make(map[int]Signature),
+	}
+}
+
+// Returns the number of bands and rows per band (with bands*rows <= numHashes)
+// whose similarity threshold (1/bands)^(1/rows) is closest to threshold.
+func BandsAndRows(numHashes int, threshold float64) (bands int, rows int) {
+	bestDiff := math.Inf(1)
+	for r := 1; r <= numHashes; r++ {
+		b := numHashes / r
+		diff := math.Abs(math.Pow(1.0/float64(b), 1.0/float64(r)) - threshold)
+		if diff < bestDiff {
+			bestDiff, bands, rows = diff, b, r
+		}
+	}
+	return bands, rows
+}
+
+// Returns the number of documents in this index.
+func (me *LSHIndex) Size() int {
+	return len(me.docIds)
+}
+
+// Adds the signature of the specified document to this index.
+func (me *LSHIndex) Add(docId int, sig Signature) error {
+	if len(sig) < me.bands*me.rows {
+		return fmt.Errorf("signature has %v elements; at least %v required", len(sig), me.bands*me.rows)
+	}
+	if _, found := me.signatures[docId]; found {
+		return fmt.Errorf("document %v has already been added", docId)
+	}
+
+	me.signatures[docId] = sig
+	me.docIds = append(me.docIds, docId)
+
+	for band := 0; band < me.bands; band++ {
+		h := me.bandHash(sig, band)
+		me.buckets[band][h] = append(me.buckets[band][h], docId)
+	}
+	return nil
+}
+
+// Returns the Ids of the documents in this index that share at least one bucket
+// with the specified signature, in ascending order.
+func (me *LSHIndex) Candidates(sig Signature) []int {
+	seen := map[int]bool{}
+	candidates := []int{}
+
+	for band := 0; band < me.bands && len(sig) >= me.bands*me.rows; band++ {
+		for _, docId := range me.buckets[band][me.bandHash(sig, band)] {
+			if !seen[docId] {
+				seen[docId] = true
+				candidates = append(candidates, docId)
+			}
+		}
+	}
+
+	sort.Ints(candidates)
+	return candidates
+}
+
+// Returns the pairs of documents that share at least one bucket and whose
+// estimated Jaccard similarity is >= threshold, sorted by decreasing
+// similarity.  Within each pair, Id1 is the document that was added first.
+func (me *LSHIndex) CandidatePairs(threshold float64) []Pair {
+	position := make(map[int]int, len(me.docIds))
+	for i, docId := range me.docIds {
+		position[docId] = i
+	}
+
+	type docPair struct{ a, b int }
+	seen := map[docPair]bool{}
+	pairs := []Pair{}
+
+	for band := 0; band < me.bands; band++ {
+		for _, bucket := range me.buckets[band] {
+			for i := 0; i < len(bucket); i++ {
+				for j := i + 1; j < len(bucket); j++ {
+					a, b := bucket[i], bucket[j]
+					if position[a] > position[b] {
+						a, b = b, a
+					}
+					if seen[docPair{a, b}] {
+						continue
+					}
+					seen[docPair{a, b}] = true
+
+					similarity := EstimateJaccard(me.signatures[a], me.signatures[b])
+					if similarity >= threshold {
+						pairs = append(pairs, Pair{Id1: a, Id2: b, Similarity: similarity})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		if pairs[i].Id1 != pairs[j].Id1 {
+			return position[pairs[i].Id1] < position[pairs[j].Id1]
+		}
+		return position[pairs[i].Id2] < position[pairs[j].Id2]
+	})
+	return pairs
+}
+
+// Hashes the rows of sig that belong to the specified band.
+func (me *LSHIndex) bandHash(sig Signature, band int) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, v := range sig[band*me.rows : (band+1)*me.rows] {
+		binary.LittleEndian.PutUint64(buf, v)
+		h.Write(buf)
+	}
+	return h.Sum64()
+}
+
+// Sorts the specified values and removes duplicates.
+func uniq(values []uint64) []uint64 {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	unique := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
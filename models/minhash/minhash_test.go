@@ -0,0 +1,130 @@
+package minhash
+
+import (
+	"fmt"
+	"github.com/cet001/gosim"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func ExampleLSHIndex() {
+	corpus := []string{
+		"The quick brown fox jumps over the lazy dog near the river bank today",
+		"The quick brown fox jumps over the lazy dog near the river bank tonight",
+		"Completely unrelated text about cooking pasta with tomato sauce and basil",
+		"The quick brown fox leaps over the lazy dog near the river bank today",
+	}
+
+	tokenize := gosim.MakeDefaultTokenizer()
+	hasher := NewMinHasher(128, 42)
+	index := NewLSHIndex(BandsAndRows(128, 0.5))
+
+	for docId, doc := range corpus {
+		shingles := Shingles(tokenize(doc), 2)
+		index.Add(docId, hasher.Signature(shingles))
+	}
+
+	for _, pair := range index.CandidatePairs(0.5) {
+		fmt.Printf("%v <-> %v\n", pair.Id1, pair.Id2)
+	}
+	// Output:
+	// 0 <-> 1
+	// 0 <-> 3
+	// 1 <-> 3
+}
+
+func TestShingles(t *testing.T) {
+	tokens := []string{"a", "b", "c", "a", "b"}
+
+	shingles := Shingles(tokens, 2)
+	assert.Equal(t, 3, len(shingles)) // {a b}, {b c}, {c a}
+	for i := 0; i < len(shingles)-1; i++ {
+		assert.True(t, shingles[i] < shingles[i+1])
+	}
+
+	assert.Equal(t, 3, len(Shingles(tokens, 1)))
+	assert.Equal(t, 1, len(Shingles(tokens, 10)))
+	assert.Equal(t, []uint64{}, Shingles([]string{}, 2))
+
+	// Token boundaries matter: {"ab", "c"} != {"a", "bc"}
+	assert.NotEqual(t, Shingles([]string{"ab", "c"}, 2), Shingles([]string{"a", "bc"}, 2))
+}
+
+func TestJaccard(t *testing.T) {
+	assert.Equal(t, 0.5, Jaccard([]uint64{1, 2, 3}, []uint64{2, 3, 4}))
+	assert.Equal(t, 1.0, Jaccard([]uint64{1, 2}, []uint64{1, 2}))
+	assert.Equal(t, 0.0, Jaccard([]uint64{1}, []uint64{2}))
+	assert.Equal(t, 0.0, Jaccard([]uint64{}, []uint64{}))
+}
+
+func TestMinHasher_Signature(t *testing.T) {
+	hasher := NewMinHasher(256, 1)
+	assert.Equal(t, 256, hasher.NumHashes())
+
+	a := make([]uint64, 0, 100)
+	b := make([]uint64, 0, 100)
+	for i := uint64(0); i < 100; i++ {
+		a = append(a, i*7919)
+		b = append(b, (i+50)*7919) // 50 shared elements out of 150
+	}
+
+	sigA, sigB := hasher.Signature(a), hasher.Signature(b)
+	assert.Equal(t, sigA, NewMinHasher(256, 1).Signature(a))
+	assert.Equal(t, 1.0, EstimateJaccard(sigA, sigA))
+	assert.InDelta(t, Jaccard(a, b), EstimateJaccard(sigA, sigB), 0.1)
+
+	empty := hasher.Signature([]uint64{})
+	assert.Equal(t, uint64(math.MaxUint64), empty[0])
+	assert.Equal(t, 0.0, EstimateJaccard(sigA, Signature{}))
+}
+
+func TestHashMod(t *testing.T) {
+	p := new(big.Int).SetUint64(mersennePrime)
+	values := []uint64{0, 1, 12345, mersennePrime - 1, 1 << 60}
+
+	for _, a := range values {
+		for _, x := range values {
+			for _, b := range values {
+				expected := new(big.Int).SetUint64(a)
+				expected.Mul(expected, new(big.Int).SetUint64(x))
+				expected.Add(expected, new(big.Int).SetUint64(b))
+				expected.Mod(expected, p)
+				assert.Equal(t, expected.Uint64(), hashMod(a, x, b))
+			}
+		}
+	}
+}
+
+func TestBandsAndRows(t *testing.T) {
+	bands, rows := BandsAndRows(128, 0.8)
+	assert.True(t, bands*rows <= 128)
+	assert.InDelta(t, 0.8, math.Pow(1.0/float64(bands), 1.0/float64(rows)), 0.05)
+}
+
+func TestLSHIndex(t *testing.T) {
+	index := NewLSHIndex(4, 2)
+
+	sig1 := Signature{1, 2, 3, 4, 5, 6, 7, 8}
+	sig2 := Signature{1, 2, 3, 4, 5, 6, 7, 9} // shares bands 0-2 with sig1
+	sig3 := Signature{9, 9, 9, 9, 9, 9, 7, 9} // shares band 3 with sig2
+	sig4 := Signature{0, 0, 0, 0, 0, 0, 0, 0} // shares nothing
+	assert.Nil(t, index.Add(10, sig1))
+	assert.Nil(t, index.Add(20, sig2))
+	assert.Nil(t, index.Add(30, sig3))
+	assert.Nil(t, index.Add(40, sig4))
+	assert.Equal(t, 4, index.Size())
+
+	assert.NotNil(t, index.Add(10, sig1))
+	assert.NotNil(t, index.Add(50, Signature{1, 2, 3}))
+
+	assert.Equal(t, []int{10, 20}, index.Candidates(sig1))
+	assert.Equal(t, []int{10, 20, 30}, index.Candidates(sig2))
+
+	assert.Equal(t,
+		[]Pair{{Id1: 10, Id2: 20, Similarity: 7.0 / 8.0}, {Id1: 20, Id2: 30, Similarity: 2.0 / 8.0}},
+		index.CandidatePairs(0.0),
+	)
+	assert.Equal(t, []Pair{{Id1: 10, Id2: 20, Similarity: 7.0 / 8.0}}, index.CandidatePairs(0.5))
+}
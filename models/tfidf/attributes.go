@@ -0,0 +1,152 @@
+package tfidf
+
+import (
+	"errors"
+	"math"
+)
+
+// Optional metadata of a document, which queries can filter on (see Filter).
+// Attributes are saved along with the documents.
+type Attributes struct {
+	// String tags, e.g. {"tenant": "acme", "lang": "en"}.
+	Tags map[string]string
+
+	// Numeric fields, e.g. {"published": 20200131}.
+	Fields map[string]float64
+}
+
+// Restricts the documents that a query can match, while the query is being
+// scored (see QueryOptions.Filter).  A document matches if it satisfies all of
+// the specified conditions.
+type Filter struct {
+	// The document must have each of these tags, with the specified value.
+	Tags map[string]string
+
+	// The document must have each of these numeric fields, with a value
+	// within the specified range.
+	Fields map[string]Range
+
+	// If not nil, only documents with these Ids can match.
+	AllowIds []int
+
+	// Documents with these Ids never match.
+	DenyIds []int
+}
+
+// An inclusive range of numeric field values.  Use AtLeast() and AtMost() for
+// ranges that are unbounded at one end.
+type Range struct {
+	Min float64
+	Max float64
+}
+
+// Returns the range [min..max].
+func Between(min, max float64) Range {
+	return Range{Min: min, Max: max}
+}
+
+// Returns the range of values >= min.
+func AtLeast(min float64) Range {
+	return Range{Min: min, Max: math.Inf(1)}
+}
+
+// Returns the range of values <= max.
+func AtMost(max float64) Range {
+	return Range{Min: math.Inf(-1), Max: max}
+}
+
+// Returns true if value is within this range.
+func (r Range) Contains(value float64) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+// Returned by MappedTFIDF.Search() when opts.Filter has Tags or Fields, since
+// mapped model files do not hold the attributes of the documents.
+var ErrNoAttributes = errors.New("tfidf: model has no document attributes")
+
+// Returns true if the filter has conditions on the attributes of a document
+// (as opposed to only its Id).
+func (me *Filter) usesAttributes() bool {
+	return len(me.Tags) > 0 || len(me.Fields) > 0
+}
+
+// A Filter that has been prepared for matching many documents.
+type compiledFilter struct {
+	*Filter
+	allowed map[int]bool
+	denied  map[int]bool
+}
+
+func (me *Filter) compile() *compiledFilter {
+	compiled := &compiledFilter{Filter: me}
+	if me.AllowIds != nil {
+		compiled.allowed = make(map[int]bool, len(me.AllowIds))
+		for _, docId := range me.AllowIds {
+			compiled.allowed[docId] = true
+		}
+	}
+	compiled.denied = make(map[int]bool, len(me.DenyIds))
+	for _, docId := range me.DenyIds {
+		compiled.denied[docId] = true
+	}
+	return compiled
+}
+
+// Returns true if the specified document matches the filter.
+func (me *compiledFilter) matches(docId int, attrs *Attributes) bool {
+	if me.allowed != nil && !me.allowed[docId] {
+		return false
+	}
+	if me.denied[docId] {
+		return false
+	}
+
+	for name, value := range me.Tags {
+		if tag, found := attrs.Tags[name]; !found || tag != value {
+			return false
+		}
+	}
+	for name, r := range me.Fields {
+		if value, found := attrs.Fields[name]; !found || !r.Contains(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns a predicate that tells whether the document at the specified index
+// within me.docs matches the filter, or nil if the filter is nil (i.e. every
+// document matches).
+func (me *TFIDF) docFilter(filter *Filter) func(docIdx int) bool {
+	if filter == nil {
+		return nil
+	}
+
+	compiled := filter.compile()
+	return func(docIdx int) bool {
+		doc := &me.docs[docIdx]
+		return compiled.matches(doc.Id, &doc.Attributes)
+	}
+}
+
+// Returns the attributes of the document with the specified Id, or
+// ErrUnknownDocId if the corpus contains no such document.
+func (me *TFIDF) Attributes(docId int) (Attributes, error) {
+	docIdx, found := me.docIndex[docId]
+	if !found {
+		return Attributes{}, ErrUnknownDocId
+	}
+	return me.docs[docIdx].Attributes, nil
+}
+
+// Replaces the attributes of the document with the specified Id.  Returns
+// ErrUnknownDocId if the corpus contains no such document.  Like AddDoc(),
+// this method must not be called concurrently with queries.
+func (me *TFIDF) SetAttributes(docId int, attrs Attributes) error {
+	docIdx, found := me.docIndex[docId]
+	if !found {
+		return ErrUnknownDocId
+	}
+	me.docs[docIdx].Attributes = attrs
+	return nil
+}
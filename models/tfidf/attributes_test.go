@@ -0,0 +1,197 @@
+package tfidf
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestRange_Contains(t *testing.T) {
+	assert.True(t, Between(1, 2).Contains(1))
+	assert.True(t, Between(1, 2).Contains(2))
+	assert.False(t, Between(1, 2).Contains(2.5))
+	assert.True(t, AtLeast(1).Contains(math.MaxFloat64))
+	assert.False(t, AtLeast(1).Contains(0.5))
+	assert.True(t, AtMost(1).Contains(-math.MaxFloat64))
+	assert.False(t, AtMost(1).Contains(1.5))
+}
+
+func TestFilter_matches(t *testing.T) {
+	attrs := &Attributes{
+		Tags:   map[string]string{"tenant": "acme", "lang": "en"},
+		Fields: map[string]float64{"date": 20200131},
+	}
+
+	testCases := []struct {
+		filter   Filter
+		expected bool
+	}{
+		{Filter{}, true},
+		{Filter{Tags: map[string]string{"tenant": "acme"}}, true},
+		{Filter{Tags: map[string]string{"tenant": "acme", "lang": "fr"}}, false},
+		{Filter{Tags: map[string]string{"missing": ""}}, false},
+		{Filter{Fields: map[string]Range{"date": AtLeast(20200101)}}, true},
+		{Filter{Fields: map[string]Range{"date": Between(20200201, 20200229)}}, false},
+		{Filter{Fields: map[string]Range{"missing": AtLeast(math.Inf(-1))}}, false},
+		{Filter{AllowIds: []int{1, 7}}, true},
+		{Filter{AllowIds: []int{1}}, false},
+		{Filter{AllowIds: []int{}}, false},
+		{Filter{DenyIds: []int{7}}, false},
+		{Filter{AllowIds: []int{7}, DenyIds: []int{7}}, false},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.filter.compile().matches(7, attrs), fmt.Sprintf("testCases[%v]", i))
+	}
+
+	// Documents without attributes only match filters on Ids.
+	assert.True(t, (&Filter{DenyIds: []int{1}}).compile().matches(7, &Attributes{}))
+	assert.False(t, (&Filter{Tags: map[string]string{"tenant": "acme"}}).compile().matches(7, &Attributes{}))
+}
+
+// Verifies that filtered searches return exactly the matching documents of the
+// full ranking, with and without pruning.
+func TestTFIDF_Search_filter(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	corpus := makeRandomCorpus(rng, 1000, 2000, 30)
+	tenants := []string{"acme", "globex", "initech"}
+
+	for _, workers := range []int{1, 4} {
+		model := NewTFIDF()
+		model.Logger = NopLogger
+		model.StopWordThreshold = 0.5
+		model.Workers = workers
+		for docId, doc := range corpus {
+			model.AddDocWithAttributes(docId, doc, Attributes{
+				Tags:   map[string]string{"tenant": tenants[docId%len(tenants)]},
+				Fields: map[string]float64{"date": float64(docId)},
+			})
+		}
+		model.Train()
+
+		filters := []*Filter{
+			{Tags: map[string]string{"tenant": "globex"}},
+			{Tags: map[string]string{"tenant": "acme"}, Fields: map[string]Range{"date": Between(100, 500)}},
+			{AllowIds: []int{1, 2, 3, 500, 999}},
+			{DenyIds: []int{0, 3, 6, 9, 12, 15}},
+			{Tags: map[string]string{"tenant": "none"}},
+		}
+
+		for i := 0; i < 50; i++ {
+			query := makeRandomCorpus(rng, 1, 2000, 8)[0]
+			all := matchedItems(model.SimilarDocsForText(query))
+
+			for j, filter := range filters {
+				msg := fmt.Sprintf("workers=%v, query=%v, filters[%v]", workers, i, j)
+				compiled := filter.compile()
+				matching := []ScoredItem{}
+				for _, item := range all {
+					attrs, _ := model.Attributes(item.Id)
+					if compiled.matches(item.Id, &attrs) {
+						matching = append(matching, item)
+					}
+				}
+
+				assert.Equal(t, matching, model.Search(query, QueryOptions{Filter: filter}), msg)
+
+				expected := matching
+				if len(expected) > 5 {
+					expected = expected[:5]
+				}
+				assert.Equal(t, expected, model.Search(query, QueryOptions{K: 5, Filter: filter}), msg)
+			}
+		}
+	}
+}
+
+func TestTFIDF_Attributes(t *testing.T) {
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	acme := Attributes{Tags: map[string]string{"tenant": "acme"}}
+	globex := Attributes{Tags: map[string]string{"tenant": "globex"}}
+
+	model.AddDocWithAttributes(10, vectors.SparseVector{{Id: 1, Value: 1}}, acme)
+	model.AddDoc(20, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}})
+	model.Train()
+
+	attrs, err := model.Attributes(10)
+	assert.Nil(t, err)
+	assert.Equal(t, acme, attrs)
+	attrs, err = model.Attributes(20)
+	assert.Nil(t, err)
+	assert.Equal(t, Attributes{}, attrs)
+
+	query := vectors.SparseVector{{Id: 1, Value: 1}}
+	opts := QueryOptions{Filter: &Filter{Tags: map[string]string{"tenant": "acme"}}}
+	assert.Equal(t, []int{10}, scoredItemIds(model.Search(query, opts)))
+
+	// Attributes can be changed, and survive ReplaceDoc().
+	assert.Nil(t, model.SetAttributes(20, acme))
+	assert.Nil(t, model.SetAttributes(10, globex))
+	assert.Nil(t, model.ReplaceDoc(20, vectors.SparseVector{{Id: 1, Value: 2}}))
+	assert.Equal(t, []int{20}, scoredItemIds(model.Search(query, opts)))
+
+	// Documents added to a trained model can be filtered right away.
+	model.AddDocWithAttributes(30, vectors.SparseVector{{Id: 1, Value: 1}}, acme)
+	assert.ElementsMatch(t, []int{20, 30}, scoredItemIds(model.Search(query, opts)))
+
+	model.RemoveDoc(30)
+	_, err = model.Attributes(30)
+	assert.Equal(t, ErrUnknownDocId, err)
+	assert.Equal(t, ErrUnknownDocId, model.SetAttributes(30, acme))
+	assert.Equal(t, []int{20}, scoredItemIds(model.Search(query, opts)))
+}
+
+func TestSaveAndLoadTFIDF_attributes(t *testing.T) {
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	attrs := Attributes{
+		Tags:   map[string]string{"tenant": "acme"},
+		Fields: map[string]float64{"date": 20200131},
+	}
+	model.AddDocWithAttributes(10, vectors.SparseVector{{Id: 1, Value: 1}}, attrs)
+	model.AddDoc(20, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}})
+	model.Train()
+
+	dataFile := "/tmp/gosim_TestSaveAndLoadTFIDF_attributes.dat"
+	defer os.Remove(dataFile)
+	assert.Nil(t, model.Save(dataFile))
+
+	loaded, err := LoadTFIDF(dataFile)
+	assert.Nil(t, err)
+	loadedAttrs, err := loaded.Attributes(10)
+	assert.Nil(t, err)
+	assert.Equal(t, attrs, loadedAttrs)
+
+	query := vectors.SparseVector{{Id: 1, Value: 1}}
+	opts := QueryOptions{Filter: &Filter{Fields: map[string]Range{"date": AtLeast(20200101)}}}
+	assert.Equal(t, model.Search(query, opts), loaded.Search(query, opts))
+	assert.Equal(t, []int{10}, scoredItemIds(loaded.Search(query, opts)))
+}
+
+func TestMappedTFIDF_Search_filter(t *testing.T) {
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	model.AddDocWithAttributes(10, vectors.SparseVector{{Id: 1, Value: 1}}, Attributes{Tags: map[string]string{"tenant": "acme"}})
+	model.AddDoc(20, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}})
+	model.AddDoc(30, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 3, Value: 1}})
+	model.Train()
+
+	var buf bytes.Buffer
+	assert.Nil(t, model.encodeMapped(&buf))
+	mapped, err := newMappedTFIDF(buf.Bytes())
+	assert.Nil(t, err)
+
+	query := vectors.SparseVector{{Id: 1, Value: 1}}
+	opts := QueryOptions{Filter: &Filter{AllowIds: []int{20, 30}, DenyIds: []int{30}}}
+	assert.Equal(t, []int{20}, scoredItemIds(mapped.Search(query, opts)))
+	assert.Equal(t, model.Search(query, opts), mapped.Search(query, opts))
+
+	_, err = mapped.TrySearch(query, QueryOptions{Filter: &Filter{Tags: map[string]string{"tenant": "acme"}}})
+	assert.Equal(t, ErrNoAttributes, err)
+}
@@ -0,0 +1,119 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"sync"
+	"sync/atomic"
+)
+
+// Runs Search() for each of the queries, and returns their results in the same
+// order as the queries.  Queries that Search() would return no results for
+// (e.g. empty queries) get empty results.
+//
+// The queries are spread over Workers goroutines, each of which runs one query
+// at a time and reuses its scratch buffers from one query to the next.
+// Returns ErrNotTrained if the model has not been trained.
+func (me *TFIDF) SearchBatch(queries []vectors.SparseVector, opts QueryOptions) ([][]ScoredItem, error) {
+	results := make([][]ScoredItem, len(queries))
+	err := me.searchBatch(queries, opts, false, func(queryIdx int, items []ScoredItem) error {
+		results[queryIdx] = items
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Does what SearchBatch() does, but passes the results of each query to fn as
+// soon as they are ready, instead of collecting them, so that the memory used
+// does not grow with the number of queries.
+//
+// fn is called once per query, by one goroutine at a time, but not necessarily
+// in the order of the queries.  The items slice is reused for later queries, so
+// fn must copy any items that it needs to keep once it returns.  If fn returns
+// an error, no more queries are started, and the error is returned.
+func (me *TFIDF) SearchBatchFunc(queries []vectors.SparseVector, opts QueryOptions, fn func(queryIdx int, items []ScoredItem) error) error {
+	return me.searchBatch(queries, opts, true, fn)
+}
+
+// Runs the queries on (up to) Workers goroutines, which take the next query as
+// soon as they are done with the previous one.  If reuseResults is true, each
+// goroutine reuses a single result slice for all of its queries.
+func (me *TFIDF) searchBatch(queries []vectors.SparseVector, opts QueryOptions, reuseResults bool, fn func(queryIdx int, items []ScoredItem) error) error {
+	if err := me.checkState(); err != nil {
+		return err
+	}
+
+	opts = opts.normalized()
+	filter := me.docFilter(opts.Filter)
+	workers := me.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(queries) {
+		workers = len(queries)
+	}
+
+	var (
+		nextQuery int64 = -1
+		stopped   int32
+		fnMutex   sync.Mutex
+		fnErr     error
+		wg        sync.WaitGroup
+	)
+
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := &searchBuffers{acc: me.getAccumulator()}
+			defer me.accumulators.Put(buf.acc)
+
+			var rankedDocs []ScoredItem
+			for atomic.LoadInt32(&stopped) == 0 {
+				queryIdx := int(atomic.AddInt64(&nextQuery, 1))
+				if queryIdx >= len(queries) {
+					return
+				}
+
+				if !reuseResults {
+					rankedDocs = nil
+				}
+				var items []ScoredItem
+				rankedDocs, items = me.searchBatchQuery(queries[queryIdx], opts, filter, buf, rankedDocs)
+
+				fnMutex.Lock()
+				if fnErr == nil {
+					if err := fn(queryIdx, items); err != nil {
+						fnErr = err
+						atomic.StoreInt32(&stopped, 1)
+					}
+				}
+				fnMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return fnErr
+}
+
+// Runs a single query of a batch.  Returns rankedDocs, to which the ranked
+// documents were appended (for reuse by the next query), and the query's
+// results, which are a slice of rankedDocs.
+func (me *TFIDF) searchBatchQuery(query vectors.SparseVector, opts QueryOptions, filter func(docIdx int) bool, buf *searchBuffers, rankedDocs []ScoredItem) ([]ScoredItem, []ScoredItem) {
+	// Like Search(), return no results for queries that cannot match anything
+	// (ErrEmptyQuery, ErrZeroNorm).
+	queryTFIDF, err := me.weighQuery(query)
+	if err != nil {
+		return rankedDocs, []ScoredItem{}
+	}
+
+	rankedDocs = me.searchWith(queryTFIDF, opts, filter, buf, rankedDocs)
+	items := limitResults(rankedDocs, opts)
+	attachTopTerms(items, queryTFIDF, opts, func(docId int) vectors.SparseVector {
+		return me.docs[me.docIndex[docId]].TFIDF
+	})
+	return rankedDocs, items
+}
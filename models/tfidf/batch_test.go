@@ -0,0 +1,100 @@
+package tfidf
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestTFIDF_SearchBatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	corpus := makeRandomCorpus(rng, 1000, 2000, 30)
+	queries := makeRandomCorpus(rng, 60, 2000, 6)
+	queries = append(queries, vectors.SparseVector{}, vectors.SparseVector{{Id: 99999, Value: 1}})
+
+	for _, workers := range []int{0, 1, 4} {
+		model := NewTFIDF()
+		model.Logger = NopLogger
+		model.StopWordThreshold = 0.5
+		model.Workers = workers
+		for docId, doc := range corpus {
+			model.AddDocWithAttributes(docId, doc, Attributes{Tags: map[string]string{"parity": fmt.Sprint(docId % 2)}})
+		}
+		model.Train()
+
+		for i, opts := range []QueryOptions{
+			{},
+			{K: 5},
+			{K: 3, Offset: 2, TopTerms: 2},
+			{MinScore: 0.1, Offset: 1},
+			{K: 10, Filter: &Filter{Tags: map[string]string{"parity": "1"}}},
+		} {
+			msg := fmt.Sprintf("workers=%v, opts[%v]", workers, i)
+
+			results, err := model.SearchBatch(queries, opts)
+			assert.Nil(t, err, msg)
+			assert.Equal(t, len(queries), len(results), msg)
+			for j, query := range queries {
+				assert.Equal(t, model.Search(query, opts), results[j], msg)
+			}
+
+			streamed := make([][]ScoredItem, len(queries))
+			err = model.SearchBatchFunc(queries, opts, func(queryIdx int, items []ScoredItem) error {
+				assert.Nil(t, streamed[queryIdx], msg)
+				streamed[queryIdx] = append([]ScoredItem{}, items...)
+				return nil
+			})
+			assert.Nil(t, err, msg)
+			assert.Equal(t, results, streamed, msg)
+		}
+	}
+}
+
+func TestTFIDF_SearchBatchFunc_stop(t *testing.T) {
+	model, queries := makeBenchmarkModel(2000)
+	model.Workers = 4
+
+	stop := errors.New("stop")
+	calls := 0
+	err := model.SearchBatchFunc(append(queries, queries...), QueryOptions{K: 5}, func(queryIdx int, items []ScoredItem) error {
+		calls++
+		if calls == 10 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 10, calls)
+}
+
+func TestTFIDF_SearchBatch_errors(t *testing.T) {
+	model := NewTFIDF()
+	model.AddDoc(1, vectors.SparseVector{{Id: 1, Value: 1}})
+
+	_, err := model.SearchBatch([]vectors.SparseVector{{{Id: 1, Value: 1}}}, QueryOptions{})
+	assert.Equal(t, ErrNotTrained, err)
+
+	model.Train()
+	results, err := model.SearchBatch([]vectors.SparseVector{}, QueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]ScoredItem{}, results)
+}
+
+func BenchmarkTFIDF_SearchBatch(b *testing.B) {
+	model, queries := makeBenchmarkModel(20000)
+	model.Workers = 4
+	batch := make([]vectors.SparseVector, 1000)
+	for i := range batch {
+		batch[i] = queries[i%len(queries)]
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		model.SearchBatchFunc(batch, QueryOptions{K: 10}, func(queryIdx int, items []ScoredItem) error {
+			return nil
+		})
+	}
+}
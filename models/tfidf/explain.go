@@ -0,0 +1,186 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"sort"
+)
+
+// Maps term Ids back to words.  Implemented by *gosim.Dictionary.
+type Vocabulary interface {
+	Word(termId int) string
+}
+
+// A term and its associated score (e.g. its contribution to a similarity
+// score, or its TF-IDF weight within a document).
+type TermScore struct {
+	TermId int
+
+	// The term's word, if it was resolved through a Vocabulary.
+	Word string
+
+	Score float64
+}
+
+// A list of terms and their scores, as returned by TopTerms() or attached to
+// search results (see QueryOptions.TopTerms).
+type TermScores []TermScore
+
+// Sets the Word of each of the terms.
+func (me TermScores) ResolveWords(vocab Vocabulary) {
+	for i := range me {
+		me[i].Word = vocab.Word(me[i].TermId)
+	}
+}
+
+// Explains the similarity score of a document for a query (see Explain()).
+type Explanation struct {
+	DocId int
+
+	// The document's score, as returned by SimilarDocsForText().
+	Score float64
+
+	// The dot product of the weighted query and document vectors, which is the
+	// sum of the contributions of the shared terms.
+	Dot float64
+
+	// The L2 norms of the weighted query and document vectors.
+	QueryNorm float64
+	DocNorm   float64
+
+	// The terms that the weighted query and document vectors have in common,
+	// in decreasing order of their contribution to the dot product.
+	Terms []TermExplanation
+}
+
+// The contribution of a single term to a document's score.
+type TermExplanation struct {
+	// Score is the term's contribution to the dot product (QueryWeight *
+	// DocWeight).  Word is set by Explanation.ResolveWords().
+	TermScore
+
+	// The term frequencies within the query and the document.
+	QueryTF float64
+	DocTF   float64
+
+	// The term's inverse document frequency.
+	IDF float64
+
+	// The term's weights within the weighted query and document vectors.
+	QueryWeight float64
+	DocWeight   float64
+}
+
+// Explains how the score of the specified document for the specified query is
+// calculated, term by term.  Returns the same errors as TrySimilarDocsForText(),
+// or ErrUnknownDocId if the corpus contains no document with the specified Id.
+func (me *TFIDF) Explain(query vectors.SparseVector, docId int) (Explanation, error) {
+	queryTFIDF, err := me.weighQuery(query)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	docIdx, found := me.docIndex[docId]
+	if !found {
+		return Explanation{}, ErrUnknownDocId
+	}
+	doc := &me.docs[docIdx]
+
+	queryTFs := make(sparseHashVector, len(query))
+	for _, term := range query {
+		queryTFs[term.Id] = term.Value
+	}
+	docTFs := make(sparseHashVector, len(doc.TF))
+	for _, term := range doc.TF {
+		docTFs[term.Id] = term.Value
+	}
+	docWeights := make(sparseHashVector, len(doc.TFIDF))
+	for _, term := range doc.TFIDF {
+		docWeights[term.Id] = term.Value
+	}
+
+	explanation := Explanation{
+		DocId:     docId,
+		Dot:       vectors.Dot(queryTFIDF, doc.TFIDF),
+		QueryNorm: vectors.Norm(queryTFIDF),
+		DocNorm:   me.docNorms[docIdx],
+		Terms:     []TermExplanation{},
+	}
+	explanation.Score = me.score(explanation.Dot, explanation.QueryNorm, explanation.DocNorm)
+
+	for _, term := range queryTFIDF {
+		docWeight, found := docWeights[term.Id]
+		if !found {
+			continue
+		}
+		explanation.Terms = append(explanation.Terms, TermExplanation{
+			TermScore:   TermScore{TermId: term.Id, Score: term.Value * docWeight},
+			QueryTF:     queryTFs[term.Id],
+			DocTF:       docTFs[term.Id],
+			IDF:         me.idf[term.Id],
+			QueryWeight: term.Value,
+			DocWeight:   docWeight,
+		})
+	}
+
+	sort.SliceStable(explanation.Terms, func(i, j int) bool {
+		return explanation.Terms[i].Score > explanation.Terms[j].Score
+	})
+	return explanation, nil
+}
+
+// Sets the Word of each term in this explanation.
+func (me *Explanation) ResolveWords(vocab Vocabulary) {
+	for i := range me.Terms {
+		me.Terms[i].Word = vocab.Word(me.Terms[i].TermId)
+	}
+}
+
+// Returns the (up to) n terms that contribute the most to the dot product of
+// the weighted query and document vectors, resolving their words through vocab
+// if it is not nil.
+func topContributions(queryTFIDF, docTFIDF vectors.SparseVector, n int, vocab Vocabulary) TermScores {
+	docWeights := make(sparseHashVector, len(docTFIDF))
+	for _, term := range docTFIDF {
+		docWeights[term.Id] = term.Value
+	}
+
+	terms := TermScores{}
+	for _, term := range queryTFIDF {
+		if docWeight, found := docWeights[term.Id]; found {
+			terms = append(terms, TermScore{TermId: term.Id, Score: term.Value * docWeight})
+		}
+	}
+
+	return topTermScores(terms, n, vocab)
+}
+
+// Sorts terms by decreasing score (ties in increasing term Id order), and
+// returns the first n of them (all of them if n <= 0), resolving their words
+// through vocab if it is not nil.
+func topTermScores(terms TermScores, n int, vocab Vocabulary) TermScores {
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Score != terms[j].Score {
+			return terms[i].Score > terms[j].Score
+		}
+		return terms[i].TermId < terms[j].TermId
+	})
+	if n > 0 && len(terms) > n {
+		terms = terms[:n]
+	}
+
+	if vocab != nil {
+		terms.ResolveWords(vocab)
+	}
+	return terms
+}
+
+// Attaches the top contributing terms to each of the scored items, if
+// opts.TopTerms > 0.  docTFIDF returns the weighted vector of a document.
+func attachTopTerms(items []ScoredItem, queryTFIDF vectors.SparseVector, opts QueryOptions, docTFIDF func(docId int) vectors.SparseVector) {
+	if opts.TopTerms <= 0 {
+		return
+	}
+	for i := range items {
+		items[i].TopTerms = topContributions(queryTFIDF, docTFIDF(items[i].Id), opts.TopTerms, opts.Vocabulary)
+	}
+}
@@ -0,0 +1,117 @@
+package tfidf
+
+import (
+	"bytes"
+	"github.com/cet001/gosim"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTFIDF_Explain(t *testing.T) {
+	model, dict := newTextTestModel(explainTestCorpus)
+	tokenize := gosim.MakeDefaultTokenizer()
+	query := dict.Vectorize(tokenize("apache helicopter war"))
+
+	explanation, err := model.Explain(query, 0)
+	assert.Nil(t, err)
+	explanation.ResolveWords(dict)
+
+	assert.Equal(t, 0, explanation.DocId)
+	assert.Equal(t, model.SimilarDocsForText(query)[0], ScoredItem{Id: 0, Score: explanation.Score})
+	// "helicopter" and "war" contribute equally, and are ordered by their
+	// (dictionary dependent) term Ids.
+	words := explainedWords(explanation)
+	assert.ElementsMatch(t, []string{"helicopter", "war"}, words[:2])
+	assert.Equal(t, "apache", words[2])
+
+	sum := 0.0
+	for _, term := range explanation.Terms {
+		assert.Equal(t, model.idf[term.TermId], term.IDF)
+		assert.Equal(t, term.QueryWeight*term.DocWeight, term.Score)
+		assert.Equal(t, 1.0/3.0, term.QueryTF)
+		assert.Equal(t, 0.25, term.DocTF)
+		sum += term.Score
+	}
+	assert.InDelta(t, explanation.Dot, sum, 1e-12)
+	assert.InDelta(t, explanation.Score, explanation.Dot/(explanation.QueryNorm*explanation.DocNorm), 1e-12)
+
+	// Document 2 only shares "apache" with the query.
+	explanation, err = model.Explain(query, 2)
+	assert.Nil(t, err)
+	explanation.ResolveWords(dict)
+	assert.Equal(t, []string{"apache"}, explainedWords(explanation))
+
+	// Document 3 shares no terms with the query.
+	explanation, err = model.Explain(query, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, explanation.Score)
+	assert.Equal(t, []TermExplanation{}, explanation.Terms)
+}
+
+func TestTFIDF_Explain_errors(t *testing.T) {
+	model, _ := newTextTestModel(explainTestCorpus)
+	query := vectors.SparseVector{{Id: 1, Value: 1}}
+
+	_, err := model.Explain(query, 99)
+	assert.Equal(t, ErrUnknownDocId, err)
+	_, err = model.Explain(vectors.SparseVector{}, 0)
+	assert.Equal(t, ErrEmptyQuery, err)
+
+	model.AddDoc(99, query)
+	model.needsRecalc = true
+	_, err = model.Explain(query, 0)
+	assert.Equal(t, ErrNotTrained, err)
+}
+
+func TestTFIDF_Search_topTerms(t *testing.T) {
+	model, dict := newTextTestModel(explainTestCorpus)
+	tokenize := gosim.MakeDefaultTokenizer()
+	query := dict.Vectorize(tokenize("apache helicopter war software"))
+
+	items := model.Search(query, QueryOptions{K: 2, TopTerms: 2, Vocabulary: dict})
+	assert.Equal(t, []int{0, 1}, scoredItemIds(items))
+	assert.ElementsMatch(t, []string{"helicopter", "war"}, termWords(items[0].TopTerms))
+	assert.Equal(t, []string{"software", "apache"}, termWords(items[1].TopTerms))
+
+	explanation, _ := model.Explain(query, 1)
+	assert.Equal(t, explanation.Terms[0].Score, items[1].TopTerms[0].Score)
+
+	// Without a vocabulary, only the term Ids are set.
+	items = model.Search(query, QueryOptions{TopTerms: 1})
+	assert.Equal(t, "", items[0].TopTerms[0].Word)
+	assert.Equal(t, dict.Vectorize([]string{"software"})[0].Id, items[1].TopTerms[0].TermId)
+
+	// Top terms are only attached on request.
+	assert.Nil(t, model.Search(query, QueryOptions{})[0].TopTerms)
+
+	var buf bytes.Buffer
+	assert.Nil(t, model.encodeMapped(&buf))
+	mapped, err := newMappedTFIDF(buf.Bytes())
+	assert.Nil(t, err)
+	opts := QueryOptions{K: 3, TopTerms: 3, Vocabulary: dict}
+	assert.Equal(t, model.Search(query, opts), mapped.Search(query, opts))
+}
+
+var explainTestCorpus = []string{
+	"apache helicopter military war", // docId=0
+	"apache software code developer", // docId=1
+	"apache indian history tribe",    // docId=2
+	"foo bar baz",                    // docId=3
+}
+
+func explainedWords(explanation Explanation) []string {
+	words := []string{}
+	for _, term := range explanation.Terms {
+		words = append(words, term.Word)
+	}
+	return words
+}
+
+func termWords(terms TermScores) []string {
+	words := []string{}
+	for _, term := range terms {
+		words = append(words, term.Word)
+	}
+	return words
+}
@@ -0,0 +1,269 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"sort"
+	"sync"
+)
+
+// An entry in the postings list of a term.
+type posting struct {
+	// Index of the document within TFIDF.docs.
+	docIdx int
+
+	// The term's weight within the document (i.e. its Document.TFIDF value).
+	weight float64
+}
+
+// Inverted index: postings[t] -> the documents that contain term t, in
+// increasing order of docIdx.
+type invertedIndex map[int][]posting
+
+// Builds the inverted index of the weighted (Document.TFIDF) document vectors.
+//
+// The documents are split into contiguous ranges among the specified number of
+// workers, each of which indexes its own range.  The postings of each term are
+// then concatenated in range order, which keeps them sorted by docIdx.
+func buildIndex(docs []Document, workers int) invertedIndex {
+	chunks := make([]invertedIndex, numChunks(len(docs), workers))
+	parallelFor(len(docs), workers, func(chunk, lo, hi int) {
+		index := make(invertedIndex, 100000/len(chunks))
+		for docIdx := lo; docIdx < hi; docIdx++ {
+			for _, term := range docs[docIdx].TFIDF {
+				index[term.Id] = append(index[term.Id], posting{docIdx: docIdx, weight: term.Value})
+			}
+		}
+		chunks[chunk] = index
+	})
+
+	index := chunks[0]
+	for _, chunk := range chunks[1:] {
+		for termId, postings := range chunk {
+			index[termId] = append(index[termId], postings...)
+		}
+	}
+	return index
+}
+
+// Calculates the L2 norm of each weighted document vector.
+func calcDocNorms(docs []Document, workers int) []float64 {
+	norms := make([]float64, len(docs))
+	parallelFor(len(docs), workers, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			norms[i] = vectors.Norm(docs[i].TFIDF)
+		}
+	})
+	return norms
+}
+
+// Accumulates the partial dot products between a query and each document.
+type accumulator struct {
+	// dots[docIdx] -> the dot product accumulated so far for document docIdx.
+	dots []float64
+
+	// visited[docIdx] -> true if document docIdx has an entry in touched.
+	visited []bool
+
+	// The docIdx of each document that has received a contribution.
+	touched []int
+
+	// If not nil, only documents for which accept(docIdx) is true are added
+	// to touched.  The others are added to rejected, so that they can still be
+	// reset.
+	accept   func(docIdx int) bool
+	rejected []int
+
+	// The best partial scores, when maintained by accumulatePruned().
+	best partialScores
+}
+
+func newAccumulatorPool(numDocs int) *sync.Pool {
+	return &sync.Pool{
+		New: func() interface{} {
+			return &accumulator{
+				dots:    make([]float64, numDocs),
+				visited: make([]bool, numDocs),
+				touched: make([]int, 0, 1024),
+			}
+		},
+	}
+}
+
+// Returns a pooled accumulator that is large enough to hold every document,
+// including those added since the pool was created.
+func (me *TFIDF) getAccumulator() *accumulator {
+	acc := me.accumulators.Get().(*accumulator)
+	if n := len(me.docs) - len(acc.dots); n > 0 {
+		acc.dots = append(acc.dots, make([]float64, n)...)
+		acc.visited = append(acc.visited, make([]bool, n)...)
+	}
+	return acc
+}
+
+// Adds weight to the dot product of document docIdx.
+func (me *accumulator) add(docIdx int, weight float64) {
+	if !me.visited[docIdx] {
+		me.visited[docIdx] = true
+		if me.accept == nil || me.accept(docIdx) {
+			me.touched = append(me.touched, docIdx)
+		} else {
+			me.rejected = append(me.rejected, docIdx)
+		}
+	}
+	me.dots[docIdx] += weight
+}
+
+// Clears this accumulator so that it can be reused.
+func (me *accumulator) reset() {
+	for _, docIdxs := range [][]int{me.touched, me.rejected} {
+		for _, docIdx := range docIdxs {
+			me.dots[docIdx] = 0
+			me.visited[docIdx] = false
+		}
+	}
+	me.touched = me.touched[:0]
+	me.rejected = me.rejected[:0]
+	me.accept = nil
+}
+
+// Calculates the dot product of the weighted query vector with every document
+// that shares at least one term with it.  The contributions of the query terms
+// are added in increasing term Id order, so the results are bit-for-bit
+// identical to vectors.Dot().
+//
+// Only the documents accepted by the filter (if not nil) are touched.  The
+// caller must return the accumulator to me.accumulators when done with it.
+func (me *TFIDF) accumulate(queryTFIDF vectors.SparseVector, filter func(docIdx int) bool) *accumulator {
+	acc := me.getAccumulator()
+	me.accumulateInto(acc, queryTFIDF, filter)
+	return acc
+}
+
+// Does what accumulate() does, using the specified (reset) accumulator.
+func (me *TFIDF) accumulateInto(acc *accumulator, queryTFIDF vectors.SparseVector, filter func(docIdx int) bool) {
+	acc.accept = filter
+	for _, term := range queryTFIDF {
+		for _, p := range me.index[term.Id] {
+			acc.add(p.docIdx, term.Value*p.weight)
+		}
+	}
+}
+
+// Does what accumulate() does, but splits the corpus into contiguous ranges of
+// documents that are scored by separate workers.  Each document's dot product
+// is still accumulated in increasing term Id order, so the results are
+// identical to accumulate().  The touched documents are in increasing docIdx
+// order.
+func (me *TFIDF) accumulateParallel(queryTFIDF vectors.SparseVector, workers int, filter func(docIdx int) bool) *accumulator {
+	acc := me.getAccumulator()
+
+	// Workers only write to the dots/visited entries of their own documents,
+	// but need their own touched and rejected lists.
+	touchedPerWorker := make([][]int, numChunks(len(me.docs), workers))
+	rejectedPerWorker := make([][]int, len(touchedPerWorker))
+	parallelFor(len(me.docs), workers, func(worker, lo, hi int) {
+		touched := make([]int, 0, 256)
+		rejected := []int{}
+		for _, term := range queryTFIDF {
+			postings := me.index[term.Id]
+			start := sort.Search(len(postings), func(i int) bool { return postings[i].docIdx >= lo })
+			for _, p := range postings[start:] {
+				if p.docIdx >= hi {
+					break
+				}
+				if !acc.visited[p.docIdx] {
+					acc.visited[p.docIdx] = true
+					if filter == nil || filter(p.docIdx) {
+						touched = append(touched, p.docIdx)
+					} else {
+						rejected = append(rejected, p.docIdx)
+					}
+				}
+				acc.dots[p.docIdx] += term.Value * p.weight
+			}
+		}
+		sort.Ints(touched)
+		touchedPerWorker[worker] = touched
+		rejectedPerWorker[worker] = rejected
+	})
+
+	for worker, touched := range touchedPerWorker {
+		acc.touched = append(acc.touched, touched...)
+		acc.rejected = append(acc.rejected, rejectedPerWorker[worker]...)
+	}
+	return acc
+}
+
+// Ranks every non-empty document in the corpus against the specified weighted
+// query vector, using the inverted index.  The documents that share no term
+// with the query score 0, and documents with equal scores are ranked in the
+// order in which they were added.
+func (me *TFIDF) rankDocs(queryTFIDF vectors.SparseVector) []ScoredItem {
+	acc := me.accumulateMatching(queryTFIDF, nil)
+	defer me.accumulators.Put(acc)
+	defer acc.reset()
+
+	normQueryTFIDF := vectors.Norm(queryTFIDF)
+	rankedDocs := make([]ScoredItem, 0, len(acc.touched))
+	unmatched := []ScoredItem{}
+	touched := acc.touched
+	for docIdx := range me.docs {
+		var score float64
+		if len(touched) > 0 && touched[0] == docIdx {
+			score = me.score(acc.dots[docIdx], normQueryTFIDF, me.docNorms[docIdx])
+			touched = touched[1:]
+		} else if len(me.docs[docIdx].TFIDF) == 0 {
+			continue
+		}
+
+		item := ScoredItem{Id: me.docs[docIdx].Id, Score: score}
+		if score > 0 {
+			rankedDocs = append(rankedDocs, item)
+		} else {
+			unmatched = append(unmatched, item)
+		}
+	}
+
+	// Most of the unmatched documents score 0, and are already in order.
+	sort.Stable(byScore(rankedDocs))
+	sort.Stable(byScore(unmatched))
+	return append(rankedDocs, unmatched...)
+}
+
+// Ranks the documents accepted by the filter (all documents if the filter is
+// nil) that have a score > 0, using the inverted index.
+func (me *TFIDF) rankMatchingDocs(queryTFIDF vectors.SparseVector, filter func(docIdx int) bool) []ScoredItem {
+	acc := me.accumulateMatching(queryTFIDF, filter)
+	defer me.accumulators.Put(acc)
+	defer acc.reset()
+
+	return me.rankTouched(acc, vectors.Norm(queryTFIDF), make([]ScoredItem, 0, len(acc.touched)))
+}
+
+// Accumulates the dot products of the specified weighted query vector with the
+// documents accepted by the filter, using me.Workers workers.  The touched
+// documents are sorted by docIdx.
+func (me *TFIDF) accumulateMatching(queryTFIDF vectors.SparseVector, filter func(docIdx int) bool) *accumulator {
+	if me.Workers > 1 {
+		return me.accumulateParallel(queryTFIDF, me.Workers, filter)
+	}
+
+	acc := me.accumulate(queryTFIDF, filter)
+	sort.Ints(acc.touched)
+	return acc
+}
+
+// Appends the documents touched by the accumulator (which must be in
+// increasing docIdx order) that have a score > 0 to the empty rankedDocs, and
+// sorts them by decreasing score.
+func (me *TFIDF) rankTouched(acc *accumulator, normQueryTFIDF float64, rankedDocs []ScoredItem) []ScoredItem {
+	for _, docIdx := range acc.touched {
+		score := me.score(acc.dots[docIdx], normQueryTFIDF, me.docNorms[docIdx])
+		if score > 0 {
+			rankedDocs = append(rankedDocs, ScoredItem{Id: me.docs[docIdx].Id, Score: score})
+		}
+	}
+
+	sort.Stable(byScore(rankedDocs))
+	return rankedDocs
+}
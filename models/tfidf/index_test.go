@@ -0,0 +1,172 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBuildIndex(t *testing.T) {
+	docs := []Document{
+		{Id: 100, TFIDF: vectors.SparseVector{{Id: 1, Value: 0.1}, {Id: 2, Value: 0.2}}},
+		{Id: 200, TFIDF: vectors.SparseVector{}},
+		{Id: 300, TFIDF: vectors.SparseVector{{Id: 2, Value: 0.3}}},
+	}
+
+	for _, workers := range []int{1, 2, 3, 8} {
+		assert.Equal(t,
+			invertedIndex{
+				1: {{docIdx: 0, weight: 0.1}},
+				2: {{docIdx: 0, weight: 0.2}, {docIdx: 2, weight: 0.3}},
+			},
+			buildIndex(docs, workers),
+		)
+	}
+}
+
+func TestCalcDocNorms(t *testing.T) {
+	docs := []Document{
+		{Id: 100, TFIDF: vectors.SparseVector{{Id: 1, Value: 3}, {Id: 2, Value: 4}}},
+		{Id: 200, TFIDF: vectors.SparseVector{}},
+	}
+	assert.Equal(t, []float64{5, 0}, calcDocNorms(docs, 1))
+}
+
+func TestAccumulator(t *testing.T) {
+	acc := newAccumulatorPool(4).Get().(*accumulator)
+	acc.add(2, 0.5)
+	acc.add(0, 0.0)
+	acc.add(2, 0.25)
+	assert.Equal(t, []int{2, 0}, acc.touched)
+	assert.Equal(t, []float64{0, 0, 0.75, 0}, acc.dots)
+
+	acc.reset()
+	assert.Equal(t, []int{}, acc.touched)
+	assert.Equal(t, []float64{0, 0, 0, 0}, acc.dots)
+	assert.Equal(t, []bool{false, false, false, false}, acc.visited)
+}
+
+// Verifies that the inverted index produces the same results as a scan of the
+// whole corpus (see rankDocsBruteForce()).
+func TestTFIDF_rankDocs_matchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	corpus := makeRandomCorpus(rng, 500, 2000, 30)
+
+	for _, scheme := range []SMART{{}, {TF: 'l', IDF: 't', Norm: 'c'}, {TF: 'n', IDF: 'p', Norm: 'n'}} {
+		model := NewTFIDF()
+		model.StopWordThreshold = 0.5
+		model.Scheme = scheme
+		for docId, doc := range corpus {
+			model.AddDoc(docId, doc)
+		}
+		model.Train()
+
+		nonEmptyResults := 0
+		for i := 0; i < 50; i++ {
+			query := model.weigh(makeRandomCorpus(rng, 1, 2000, 5)[0])
+			ranked := model.rankDocs(query)
+			assertSameRanking(t, model, model.rankDocsBruteForce(query), ranked, scheme.String())
+			if len(ranked) > 0 {
+				nonEmptyResults++
+			}
+		}
+		assert.True(t, nonEmptyResults > 25, scheme.String())
+	}
+}
+
+// Asserts that ranked holds the documents of the full scan (see
+// rankDocsBruteForce()), with the same scores, and with documents that have
+// equal scores in the order in which they were added.
+func assertSameRanking(t *testing.T, model *TFIDF, scanned, ranked []ScoredItem, msg string) {
+	expected := append([]ScoredItem{}, scanned...)
+	sort.SliceStable(expected, func(i, j int) bool {
+		if expected[i].Score != expected[j].Score {
+			return expected[i].Score > expected[j].Score
+		}
+		return model.docIndex[expected[i].Id] < model.docIndex[expected[j].Id]
+	})
+	assert.Equal(t, expected, ranked, msg)
+}
+
+func BenchmarkTFIDF_rankDocs(b *testing.B) {
+	model, queries := makeBenchmarkModel(20000)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		model.rankDocs(queries[n%len(queries)])
+	}
+}
+
+func BenchmarkTFIDF_rankDocsBruteForce(b *testing.B) {
+	model, queries := makeBenchmarkModel(20000)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		model.rankDocsBruteForce(queries[n%len(queries)])
+	}
+}
+
+// Creates a trained model with numDocs random documents, along with a set of
+// weighted query vectors.
+func makeBenchmarkModel(numDocs int) (*TFIDF, []vectors.SparseVector) {
+	rng := rand.New(rand.NewSource(1))
+
+	model := NewTFIDF()
+	model.Logger = NopLogger
+	for docId, doc := range makeRandomCorpus(rng, numDocs, 50000, 100) {
+		model.AddDoc(docId, doc)
+	}
+	model.Train()
+
+	queries := []vectors.SparseVector{}
+	for _, query := range makeRandomCorpus(rng, 100, 50000, 5) {
+		queries = append(queries, model.weigh(query))
+	}
+	return model, queries
+}
+
+// Generates random term count vectors, with term Ids in the range
+// [1..vocabSize] following a Zipf distribution.
+func makeRandomCorpus(rng *rand.Rand, numDocs, vocabSize, maxDocLen int) []vectors.SparseVector {
+	zipf := rand.NewZipf(rng, 1.1, 1.0, uint64(vocabSize-1))
+
+	corpus := make([]vectors.SparseVector, numDocs)
+	for i := range corpus {
+		counts := map[int]float64{}
+		docLen := 1 + rng.Intn(maxDocLen)
+		for j := 0; j < docLen; j++ {
+			counts[1+int(zipf.Uint64())]++
+		}
+
+		doc := make(vectors.SparseVector, 0, len(counts))
+		for termId, count := range counts {
+			doc = append(doc, vectors.Element{Id: termId, Value: count})
+		}
+		sort.Sort(vectors.ByElementId(doc))
+		corpus[i] = doc
+	}
+	return corpus
+}
+
+// Ranks the documents the way SimilarDocsForText() did before the inverted
+// index was introduced: by comparing the query against every non-empty
+// document in the corpus, and sorting them by decreasing score, with ties in no
+// particular order.
+func (me *TFIDF) rankDocsBruteForce(queryTFIDF vectors.SparseVector) []ScoredItem {
+	normQueryTFIDF := vectors.Norm(queryTFIDF)
+
+	rankedDocs := []ScoredItem{}
+	for i := 0; i < len(me.docs); i++ {
+		doc := &me.docs[i]
+
+		if len(doc.TFIDF) > 0 {
+			score := me.score(vectors.Dot(queryTFIDF, doc.TFIDF), normQueryTFIDF, vectors.Norm(doc.TFIDF))
+			rankedDocs = append(rankedDocs, ScoredItem{Id: doc.Id, Score: score})
+		}
+	}
+
+	sort.Sort(byScore(rankedDocs))
+	return rankedDocs
+}
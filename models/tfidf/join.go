@@ -0,0 +1,336 @@
+package tfidf
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"github.com/cet001/mathext/vectors"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Returned by the similarity join methods when the threshold is not within
+// the range (0..1].
+var ErrInvalidThreshold = errors.New("tfidf: similarity threshold must be within (0..1]")
+
+// A pair of documents whose cosine similarity reached the threshold of a
+// similarity join (see SimilarPairsFunc()).
+type SimilarPair struct {
+	// The Ids of the documents.  Id1 is the document that was added to the
+	// corpus first.
+	Id1 int
+	Id2 int
+
+	Score float64
+}
+
+// The weighted vector of a document, prepared for a similarity join.
+type joinVector struct {
+	docIdx int
+
+	// The L2 normalized weights of the document's terms, in increasing order
+	// of the rank of their term (see SimilarPairsFunc()).
+	terms []joinTerm
+
+	// The largest of the normalized weights.
+	maxWeight float64
+
+	// terms[:prefixLen] are not indexed, and are only used to verify
+	// candidate pairs.
+	prefixLen int
+}
+
+type joinTerm struct {
+	rank   int
+	weight float64
+}
+
+// A document that has been added to the index of a similarity join,
+// identified by its position within the processing order.
+type joinPosting struct {
+	pos    int
+	weight float64
+}
+
+// Finds every pair of documents whose cosine similarity is >= threshold, and
+// passes each of them to fn.  The similarity is calculated on the weighted
+// document vectors, so for schemes that normalize by cosine (including the
+// default scheme) it is the score that SimilarDocsForText() reports.
+//
+// This is the All-Pairs algorithm of Bayardo et al., "Scaling Up All Pairs
+// Similarity Search" (WWW 2007): documents are processed in decreasing order
+// of their largest term weight, and each one is matched against an inverted
+// index of the documents processed before it.  Terms are ranked by decreasing
+// document frequency, and only the rarest terms of each document that could
+// on their own produce a match are indexed.  The remaining terms are only
+// used to verify candidates.  Each candidate pair that may reach the
+// threshold is then verified with an exact dot product.  Pruning is disabled
+// if any term weight is negative.
+//
+// Pairs are produced in no particular order.  If fn returns an error, the join
+// is stopped and the error is returned.  Returns ErrNotTrained if the model has
+// not been trained, or ErrInvalidThreshold if threshold is not within (0..1].
+func (me *TFIDF) SimilarPairsFunc(threshold float64, fn func(pair SimilarPair) error) error {
+	if err := me.checkState(); err != nil {
+		return err
+	}
+	if !(threshold > 0 && threshold <= 1) {
+		return ErrInvalidThreshold
+	}
+
+	canPrune := me.nonNegativeWeights
+	boundThreshold := threshold * (1 - boundSlack)
+	vecs, maxWeights := me.joinVectors()
+
+	index := make([][]joinPosting, len(maxWeights))
+	dots := make([]float64, len(vecs))
+	visited := make([]bool, len(vecs))
+	touched := make([]int, 0, 1024)
+
+	for pos := range vecs {
+		x := &vecs[pos]
+
+		// Find the candidates among the documents indexed so far, processing
+		// the terms from rarest to most frequent.  Once the remaining terms
+		// cannot reach the threshold on their own, only existing candidates
+		// are updated.
+		remScore := 0.0
+		for _, term := range x.terms {
+			remScore += term.weight * maxWeights[term.rank]
+		}
+		for i := len(x.terms) - 1; i >= 0; i-- {
+			term := &x.terms[i]
+			acceptNew := !canPrune || remScore >= boundThreshold
+			for _, p := range index[term.rank] {
+				if !visited[p.pos] {
+					if !acceptNew {
+						continue
+					}
+					visited[p.pos] = true
+					touched = append(touched, p.pos)
+				}
+				dots[p.pos] += term.weight * p.weight
+			}
+			remScore -= term.weight * maxWeights[term.rank]
+		}
+
+		for _, candidatePos := range touched {
+			y := &vecs[candidatePos]
+			approxScore := dots[candidatePos] + joinDot(x.terms, y.terms[:y.prefixLen])
+			dots[candidatePos] = 0
+			visited[candidatePos] = false
+			if approxScore < boundThreshold {
+				continue
+			}
+
+			if score := me.cosine(x.docIdx, y.docIdx); score >= threshold {
+				pair := SimilarPair{Id1: me.docs[y.docIdx].Id, Id2: me.docs[x.docIdx].Id, Score: score}
+				if x.docIdx < y.docIdx {
+					pair.Id1, pair.Id2 = pair.Id2, pair.Id1
+				}
+				if err := fn(pair); err != nil {
+					return err
+				}
+			}
+		}
+		touched = touched[:0]
+
+		// Index the rarest terms of the document, whose contributions to a
+		// score could reach the threshold on their own.  Documents processed
+		// later have no larger weights than x.maxWeight.
+		bound := 0.0
+		x.prefixLen = len(x.terms)
+		for i, term := range x.terms {
+			bound += math.Min(maxWeights[term.rank], x.maxWeight) * term.weight
+			if !canPrune || bound >= boundThreshold {
+				x.prefixLen = i
+				break
+			}
+		}
+		for _, term := range x.terms[x.prefixLen:] {
+			index[term.rank] = append(index[term.rank], joinPosting{pos: pos, weight: term.weight})
+		}
+	}
+
+	return nil
+}
+
+// Returns the L2 normalized vectors of the (non-empty, live) documents, in
+// decreasing order of their largest weight, along with the largest weight of
+// each term rank.  Terms are ranked by decreasing document frequency.
+func (me *TFIDF) joinVectors() ([]joinVector, []float64) {
+	termIds := make([]int, 0, len(me.index))
+	for termId := range me.index {
+		termIds = append(termIds, termId)
+	}
+	sort.Slice(termIds, func(i, j int) bool {
+		di, dj := len(me.index[termIds[i]]), len(me.index[termIds[j]])
+		if di != dj {
+			return di > dj
+		}
+		return termIds[i] < termIds[j]
+	})
+	ranks := make(map[int]int, len(termIds))
+	for rank, termId := range termIds {
+		ranks[termId] = rank
+	}
+
+	maxWeights := make([]float64, len(termIds))
+	vecs := make([]joinVector, 0, len(me.docs))
+	for docIdx := range me.docs {
+		norm := me.docNorms[docIdx]
+		if norm == 0 || me.deleted[docIdx] {
+			continue
+		}
+
+		vec := joinVector{docIdx: docIdx, terms: make([]joinTerm, 0, len(me.docs[docIdx].TFIDF))}
+		for _, term := range me.docs[docIdx].TFIDF {
+			if term.Value == 0 {
+				continue
+			}
+			rank := ranks[term.Id]
+			weight := term.Value / norm
+			vec.terms = append(vec.terms, joinTerm{rank: rank, weight: weight})
+			vec.maxWeight = math.Max(vec.maxWeight, weight)
+			maxWeights[rank] = math.Max(maxWeights[rank], weight)
+		}
+		sort.Slice(vec.terms, func(i, j int) bool { return vec.terms[i].rank < vec.terms[j].rank })
+		vecs = append(vecs, vec)
+	}
+
+	sort.SliceStable(vecs, func(i, j int) bool { return vecs[i].maxWeight > vecs[j].maxWeight })
+	return vecs, maxWeights
+}
+
+// Returns the dot product of 2 vectors that are sorted by term rank.
+func joinDot(a, b []joinTerm) float64 {
+	dot := 0.0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i].rank < b[j].rank:
+			i++
+		case a[i].rank > b[j].rank:
+			j++
+		default:
+			dot += a[i].weight * b[j].weight
+			i++
+			j++
+		}
+	}
+	return dot
+}
+
+// Returns the cosine similarity of the weighted vectors of 2 documents.
+func (me *TFIDF) cosine(docIdx1, docIdx2 int) float64 {
+	dot := vectors.Dot(me.docs[docIdx1].TFIDF, me.docs[docIdx2].TFIDF)
+	return math.Min(1, dot/(me.docNorms[docIdx1]*me.docNorms[docIdx2]))
+}
+
+// Does what SimilarPairsFunc() does, and returns the pairs in decreasing order
+// of their score (pairs with the same score are in increasing order of Id1,
+// then Id2).
+func (me *TFIDF) SimilarPairs(threshold float64) ([]SimilarPair, error) {
+	pairs := []SimilarPair{}
+	err := me.SimilarPairsFunc(threshold, func(pair SimilarPair) error {
+		pairs = append(pairs, pair)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortSimilarPairs(pairs)
+	return pairs, nil
+}
+
+// Sorts pairs by decreasing score, then by increasing Id1 and Id2.
+func sortSimilarPairs(pairs []SimilarPair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Score != pairs[j].Score {
+			return pairs[i].Score > pairs[j].Score
+		}
+		if pairs[i].Id1 != pairs[j].Id1 {
+			return pairs[i].Id1 < pairs[j].Id1
+		}
+		return pairs[i].Id2 < pairs[j].Id2
+	})
+}
+
+// Identifies a file written by SaveSimilarPairs().
+const pairsFileMagic = "gosim/pairs"
+
+// The version of the file format written by SaveSimilarPairs().
+const pairsFileVersion = 1
+
+// Does what SimilarPairsFunc() does, and writes the pairs to the specified
+// file as they are found, so that joins producing more pairs than fit in
+// memory can be processed later with ReadSimilarPairs().  Returns the number
+// of pairs written.
+func (me *TFIDF) SaveSimilarPairs(threshold float64, filePath string) (int, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+	for _, value := range []interface{}{pairsFileMagic, pairsFileVersion} {
+		if err := encoder.Encode(value); err != nil {
+			return 0, err
+		}
+	}
+
+	numPairs := 0
+	err = me.SimilarPairsFunc(threshold, func(pair SimilarPair) error {
+		numPairs++
+		return encoder.Encode(&pair)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return 0, err
+	}
+	return numPairs, file.Close()
+}
+
+// Reads the pairs in a file written by SaveSimilarPairs(), and passes each of
+// them to fn.  If fn returns an error, reading stops and the error is
+// returned.
+func ReadSimilarPairs(filePath string, fn func(pair SimilarPair) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	var magic string
+	if err := decoder.Decode(&magic); err != nil || magic != pairsFileMagic {
+		return fmt.Errorf("tfidf: %v is not a similar pairs file", filePath)
+	}
+	var version int
+	if err := decoder.Decode(&version); err != nil {
+		return err
+	}
+	if version > pairsFileVersion {
+		return fmt.Errorf("tfidf: unsupported similar pairs file version %v", version)
+	}
+
+	for {
+		var pair SimilarPair
+		if err := decoder.Decode(&pair); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(pair); err != nil {
+			return err
+		}
+	}
+}
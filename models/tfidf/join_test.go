@@ -0,0 +1,202 @@
+package tfidf
+
+import (
+	"errors"
+	"fmt"
+	"github.com/cet001/gosim"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Verifies that the similarity join finds exactly the pairs that a brute-force
+// comparison of every pair of documents finds.
+func TestTFIDF_SimilarPairs_matchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	corpus := makeJoinTestCorpus(rng, 400)
+
+	for _, scheme := range []SMART{{}, {TF: 'l', IDF: 't', Norm: 'c'}, {TF: 'n', IDF: 'p', Norm: 'n'}} {
+		model := newUntrainedTestModel(scheme, corpus)
+		model.StopWordThreshold = 0.5
+		model.Train()
+		for docId := 0; docId < len(corpus); docId += 37 {
+			assert.Nil(t, model.RemoveDoc(docId))
+		}
+
+		for _, threshold := range []float64{0.2, 0.5, 0.8, 1} {
+			msg := fmt.Sprintf("%v, threshold=%v", scheme, threshold)
+			expected := model.similarPairsBruteForce(threshold)
+			pairs, err := model.SimilarPairs(threshold)
+			assert.Nil(t, err, msg)
+			assert.Equal(t, expected, pairs, msg)
+			if threshold <= 0.8 {
+				assert.True(t, len(pairs) > 50, msg)
+			}
+		}
+	}
+}
+
+func TestTFIDF_SimilarPairs(t *testing.T) {
+	model := newTestModel(SMART{}, []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}},
+		{{Id: 3, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 4, Value: 1}},
+	})
+
+	pairs, err := model.SimilarPairs(0.9)
+	assert.Nil(t, err)
+	assert.Equal(t, []SimilarPair{{Id1: 0, Id2: 2, Score: 1}}, pairs)
+
+	pairs, err = model.SimilarPairs(0.01)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(pairs))
+	assert.Equal(t, SimilarPair{Id1: 0, Id2: 2, Score: 1}, pairs[0])
+	assert.Equal(t, []int{0, 3}, []int{pairs[1].Id1, pairs[1].Id2})
+	assert.Equal(t, []int{2, 3}, []int{pairs[2].Id1, pairs[2].Id2})
+	assert.Equal(t, pairs[1].Score, pairs[2].Score)
+}
+
+func TestTFIDF_SimilarPairsFunc_stop(t *testing.T) {
+	corpus := []vectors.SparseVector{}
+	for docId := 0; docId < 10; docId++ {
+		corpus = append(corpus, vectors.SparseVector{{Id: 1, Value: 1}})
+	}
+	model := newTestModel(SMART{}, append(corpus, vectors.SparseVector{{Id: 2, Value: 1}}))
+
+	stop := errors.New("stop")
+	calls := 0
+	err := model.SimilarPairsFunc(1, func(pair SimilarPair) error {
+		calls++
+		if calls == 5 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 5, calls)
+}
+
+func TestTFIDF_SimilarPairs_errors(t *testing.T) {
+	model := newUntrainedTestModel(SMART{}, []vectors.SparseVector{{{Id: 1, Value: 1}}})
+
+	_, err := model.SimilarPairs(0.5)
+	assert.Equal(t, ErrNotTrained, err)
+
+	model.Train()
+	for _, threshold := range []float64{0, -0.5, 1.01, math.NaN()} {
+		_, err = model.SimilarPairs(threshold)
+		assert.Equal(t, ErrInvalidThreshold, err)
+	}
+}
+
+func TestTFIDF_SaveSimilarPairs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosim")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "pairs")
+
+	rng := rand.New(rand.NewSource(6))
+	model := newTestModel(SMART{}, makeJoinTestCorpus(rng, 200))
+
+	expected := []SimilarPair{}
+	model.SimilarPairsFunc(0.5, func(pair SimilarPair) error {
+		expected = append(expected, pair)
+		return nil
+	})
+	assert.True(t, len(expected) > 0)
+
+	numPairs, err := model.SaveSimilarPairs(0.5, filePath)
+	assert.Nil(t, err)
+	assert.Equal(t, len(expected), numPairs)
+
+	pairs := []SimilarPair{}
+	err = ReadSimilarPairs(filePath, func(pair SimilarPair) error {
+		pairs = append(pairs, pair)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, expected, pairs)
+
+	stop := errors.New("stop")
+	assert.Equal(t, stop, ReadSimilarPairs(filePath, func(pair SimilarPair) error { return stop }))
+
+	// Not a pairs file.
+	assert.Nil(t, ioutil.WriteFile(filePath, []byte("hello"), 0644))
+	assert.NotNil(t, ReadSimilarPairs(filePath, func(pair SimilarPair) error { return nil }))
+	assert.NotNil(t, ReadSimilarPairs(filepath.Join(dir, "missing"), func(pair SimilarPair) error { return nil }))
+}
+
+func ExampleTFIDF_SimilarPairs() {
+	corpus := []string{
+		"the quick brown fox jumps over the lazy dog",
+		"the lazy dog sleeps all day",
+		"a quick brown fox jumped over a lazy dog",
+		"foxes and dogs are not the same",
+		"the red fox hunts the rabbit",
+		"the rabbit runs from the red fox",
+	}
+
+	model := NewTFIDF()
+	model.StopWordThreshold = 0.9 // "the" is a stop word
+	dict := gosim.NewDictionary()
+	tokenize := gosim.MakeDefaultTokenizer()
+	for docId, doc := range corpus {
+		model.AddDoc(docId, dict.VectorizeAndUpdate(tokenize(doc)))
+	}
+	model.Train()
+
+	pairs, _ := model.SimilarPairs(0.5)
+	for _, pair := range pairs {
+		fmt.Printf("%v %v %.3f\n", pair.Id1, pair.Id2, pair.Score)
+	}
+	// Output:
+	// 0 2 0.667
+	// 4 5 0.589
+}
+
+// Returns a random corpus in which half of the documents are variations of
+// other documents, so that the corpus has pairs over a wide range of
+// similarities.
+func makeJoinTestCorpus(rng *rand.Rand, numDocs int) []vectors.SparseVector {
+	corpus := makeRandomCorpus(rng, numDocs/2, 2000, 30)
+	for len(corpus) < numDocs {
+		original := corpus[rng.Intn(len(corpus))]
+		doc := make(vectors.SparseVector, 0, len(original)+2)
+		for _, term := range original {
+			if rng.Intn(4) > 0 {
+				doc = append(doc, vectors.Element{Id: term.Id, Value: term.Value + float64(rng.Intn(2))})
+			}
+		}
+		if len(doc) == 0 || rng.Intn(2) == 0 {
+			doc = append(doc, vectors.Element{Id: 3000 + rng.Intn(1000), Value: 1})
+		}
+		corpus = append(corpus, doc)
+	}
+	return corpus
+}
+
+// Compares every pair of documents, and returns the pairs whose similarity is
+// >= threshold in the order returned by SimilarPairs().
+func (me *TFIDF) similarPairsBruteForce(threshold float64) []SimilarPair {
+	pairs := []SimilarPair{}
+	for i := range me.docs {
+		for j := i + 1; j < len(me.docs); j++ {
+			if me.deleted[i] || me.deleted[j] || me.docNorms[i] == 0 || me.docNorms[j] == 0 {
+				continue
+			}
+			dot := vectors.Dot(me.docs[i].TFIDF, me.docs[j].TFIDF)
+			score := math.Min(1, dot/(vectors.Norm(me.docs[i].TFIDF)*vectors.Norm(me.docs[j].TFIDF)))
+			if score >= threshold {
+				pairs = append(pairs, SimilarPair{Id1: me.docs[i].Id, Id2: me.docs[j].Id, Score: score})
+			}
+		}
+	}
+	sortSimilarPairs(pairs)
+	return pairs
+}
@@ -0,0 +1,114 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+)
+
+// Returns the (up to) n terms with the highest TF-IDF weight within the
+// specified document, i.e. its most distinctive terms.  Returns all of the
+// document's weighted terms if n <= 0.  Use TermScores.ResolveWords() to map
+// the terms to words.
+//
+// Returns ErrNotTrained if the model has not been trained, or ErrUnknownDocId
+// if the corpus contains no document with the specified Id.
+func (me *TFIDF) TopTerms(docId, n int) (TermScores, error) {
+	if err := me.checkState(); err != nil {
+		return nil, err
+	}
+
+	docIdx, found := me.docIndex[docId]
+	if !found {
+		return nil, ErrUnknownDocId
+	}
+	return topTermScores(termScores(me.docs[docIdx].TFIDF), n, nil), nil
+}
+
+// Does what TopTerms() does, for a term frequency vector that is not part of
+// the corpus.  Returns the same errors as TrySimilarDocsForText().
+func (me *TFIDF) TopTermsForText(doc vectors.SparseVector, n int) (TermScores, error) {
+	docTFIDF, err := me.weighQuery(doc)
+	if err != nil {
+		return nil, err
+	}
+	return topTermScores(termScores(docTFIDF), n, nil), nil
+}
+
+func termScores(vec vectors.SparseVector) TermScores {
+	terms := make(TermScores, len(vec))
+	for i, term := range vec {
+		terms[i] = TermScore{TermId: term.Id, Score: term.Value}
+	}
+	return terms
+}
+
+// The most salient terms of a corpus (see SalientTerms()).
+type SalientTermsReport struct {
+	// The number of documents in the corpus.
+	DocumentCount int
+
+	// The salient terms, in decreasing order of their Score.
+	Terms []SalientTerm
+}
+
+// A term's weight statistics across a corpus.
+type SalientTerm struct {
+	// Score is the term's average weight across all documents in the corpus
+	// (counting 0 for documents that do not contain it).
+	TermScore
+
+	// The number of documents that contain the term.
+	DocFreq int
+
+	// The term's inverse document frequency.
+	IDF float64
+
+	// The term's highest weight within any single document.
+	MaxWeight float64
+}
+
+// Returns the (up to) n terms that carry the most weight across the corpus
+// (all terms if n <= 0): terms that are both distinctive and common enough to
+// characterize the corpus as a whole.  Term weights are taken from the L2
+// normalized document vectors, so that long documents do not dominate.
+//
+// Returns ErrNotTrained if the model has not been trained.
+func (me *TFIDF) SalientTerms(n int) (SalientTermsReport, error) {
+	if err := me.checkState(); err != nil {
+		return SalientTermsReport{}, err
+	}
+
+	numDocs := me.liveDocCount()
+	stats := make(map[int]*SalientTerm, len(me.index))
+	terms := make(TermScores, 0, len(me.index))
+	for termId, postings := range me.index {
+		stat := &SalientTerm{DocFreq: len(postings), IDF: me.idf[termId]}
+		totalWeight := 0.0
+		for _, p := range postings {
+			if norm := me.docNorms[p.docIdx]; norm > 0 {
+				weight := p.weight / norm
+				totalWeight += weight
+				if weight > stat.MaxWeight {
+					stat.MaxWeight = weight
+				}
+			}
+		}
+
+		stats[termId] = stat
+		terms = append(terms, TermScore{TermId: termId, Score: totalWeight / float64(numDocs)})
+	}
+
+	report := SalientTermsReport{DocumentCount: numDocs, Terms: []SalientTerm{}}
+	for _, term := range topTermScores(terms, n, nil) {
+		stat := stats[term.TermId]
+		stat.TermScore = term
+		report.Terms = append(report.Terms, *stat)
+	}
+	return report, nil
+}
+
+// Sets the Word of each term in this report.
+func (me *SalientTermsReport) ResolveWords(vocab Vocabulary) {
+	for i := range me.Terms {
+		me.Terms[i].Word = vocab.Word(me.Terms[i].TermId)
+	}
+}
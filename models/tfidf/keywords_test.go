@@ -0,0 +1,112 @@
+package tfidf
+
+import (
+	"fmt"
+	"github.com/cet001/gosim"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTFIDF_TopTerms(t *testing.T) {
+	model, dict := newTextTestModel(keywordsTestCorpus)
+
+	// Terms that are unique to the document are the most distinctive, while
+	// "apache" (which is in every document) is the least distinctive.
+	terms, err := model.TopTerms(0, 2)
+	assert.Nil(t, err)
+	terms.ResolveWords(dict)
+	assert.ElementsMatch(t, []string{"military", "war"}, termWords(terms))
+	assert.Equal(t, terms[0].Score, terms[1].Score)
+
+	all, err := model.TopTerms(0, 0)
+	assert.Nil(t, err)
+	all.ResolveWords(dict)
+	words := termWords(all)
+	assert.ElementsMatch(t, []string{"military", "war"}, words[:2])
+	assert.Equal(t, []string{"helicopter", "apache"}, words[2:])
+	assert.Equal(t, model.idf[all[3].TermId]*0.25, all[3].Score)
+
+	_, err = model.TopTerms(99, 2)
+	assert.Equal(t, ErrUnknownDocId, err)
+}
+
+func TestTFIDF_TopTermsForText(t *testing.T) {
+	model, dict := newTextTestModel(keywordsTestCorpus)
+	tokenize := gosim.MakeDefaultTokenizer()
+
+	terms, err := model.TopTermsForText(dict.Vectorize(tokenize("apache tribe unknownword")), 5)
+	assert.Nil(t, err)
+	terms.ResolveWords(dict)
+	assert.Equal(t, []string{"tribe", "apache"}, termWords(terms))
+
+	_, err = model.TopTermsForText(vectors.SparseVector{}, 5)
+	assert.Equal(t, ErrEmptyQuery, err)
+
+	model.needsRecalc = true
+	_, err = model.TopTermsForText(dict.Vectorize(tokenize("apache")), 5)
+	assert.Equal(t, ErrNotTrained, err)
+	_, err = model.TopTerms(0, 5)
+	assert.Equal(t, ErrNotTrained, err)
+}
+
+func TestTFIDF_SalientTerms(t *testing.T) {
+	model, dict := newTextTestModel(keywordsTestCorpus)
+
+	report, err := model.SalientTerms(3)
+	assert.Nil(t, err)
+	report.ResolveWords(dict)
+
+	assert.Equal(t, 4, report.DocumentCount)
+	assert.Equal(t, 3, len(report.Terms))
+
+	// With stop word removal turned off, "apache" carries the most weight, as
+	// it is in every document.  It is followed by "helicopter" (2 documents)
+	// and "history" (which is mentioned twice in 1 document).
+	assert.Equal(t, []string{"apache", "helicopter", "history"}, []string{report.Terms[0].Word, report.Terms[1].Word, report.Terms[2].Word})
+	assert.Equal(t, []int{4, 2, 1}, []int{report.Terms[0].DocFreq, report.Terms[1].DocFreq, report.Terms[2].DocFreq})
+
+	top := report.Terms[1]
+	assert.Equal(t, model.idf[top.TermId], top.IDF)
+	assert.True(t, top.MaxWeight > top.Score)
+	for i := 1; i < len(report.Terms); i++ {
+		assert.True(t, report.Terms[i-1].Score >= report.Terms[i].Score)
+	}
+
+	all, err := model.SalientTerms(0)
+	assert.Nil(t, err)
+	assert.Equal(t, len(model.index), len(all.Terms))
+}
+
+func ExampleTFIDF_TopTerms() {
+	corpus := []string{
+		"the quick brown fox jumps over the lazy dog",
+		"the lazy dog sleeps all day",
+		"the red fox hunts the rabbit, and the rabbit runs from the fox",
+	}
+
+	model := NewTFIDF()
+	model.StopWordThreshold = 0.9 // "the" is a stop word
+	dict := gosim.NewDictionary()
+	tokenize := gosim.MakeDefaultTokenizer()
+	for docId, doc := range corpus {
+		model.AddDoc(docId, dict.VectorizeAndUpdate(tokenize(doc)))
+	}
+	model.Train()
+
+	terms, _ := model.TopTerms(2, 2)
+	terms.ResolveWords(dict)
+	for _, term := range terms {
+		fmt.Printf("%v %.3f\n", term.Word, term.Score)
+	}
+	// Output:
+	// rabbit 0.323
+	// fox 0.216
+}
+
+var keywordsTestCorpus = []string{
+	"apache helicopter military war",      // docId=0
+	"apache helicopter rescue mountain",   // docId=1
+	"apache software code developer",      // docId=2
+	"apache indian history tribe history", // docId=3
+}
@@ -0,0 +1,553 @@
+package tfidf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/cet001/mathext/vectors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// A mapped model file is a flat, little-endian layout of 64-bit integers and
+// floats, which is memory-mapped and queried in place:
+//
+//	header         [9]uint64  magic, version, scheme, pivot, slope, numDocs,
+//	                          numTerms, numPostings, numDocTerms
+//	docIds         [numDocs]int64
+//	docOrder       [numDocs]int64       document indexes, sorted by document Id
+//	docNorms       [numDocs]float64
+//	docOffsets     [numDocs+1]int64     document i's terms are at
+//	                                    docTermIds[docOffsets[i]:docOffsets[i+1]]
+//	docTermIds     [numDocTerms]int64
+//	docWeights     [numDocTerms]float64
+//	termIds        [numTerms]int64      sorted
+//	termIdfs       [numTerms]float64
+//	postingOffsets [numTerms+1]int64    term i's postings are at
+//	                                    postingDocs[postingOffsets[i]:postingOffsets[i+1]]
+//	postingDocs    [numPostings]int64   document indexes, sorted
+//	postingWeights [numPostings]float64
+const (
+	mappedMagic      = "GOSIMMAP"
+	mappedVersion    = 1
+	mappedHeaderSize = 9 * 8
+)
+
+// Returned by OpenMappedTFIDF() and MappedTFIDF.Validate() when the file is not
+// a valid mapped model file.
+var ErrInvalidMappedFile = errors.New("tfidf: invalid mapped model file")
+
+// A read-only TFIDF model that is queried directly from a memory-mapped file
+// (see TFIDF.SaveMapped()), without loading it into the heap.  Opening a model
+// only reads its header, and the pages of the file are shared by all processes
+// that map it.
+//
+// Queries return the same results as the TFIDF model the file was created
+// from, and may be run concurrently.
+type MappedTFIDF struct {
+	data  []byte
+	unmap func() error
+
+	scheme SMART
+	pivot  float64
+	slope  float64
+
+	docIds         int64Array
+	docOrder       int64Array
+	docNorms       float64Array
+	docOffsets     int64Array
+	docTermIds     int64Array
+	docWeights     float64Array
+	termIds        int64Array
+	termIdfs       float64Array
+	postingOffsets int64Array
+	postingDocs    int64Array
+	postingWeights float64Array
+
+	// Pool of reusable query accumulators.
+	accumulators *sync.Pool
+}
+
+// An array of little-endian int64 values within a mapped file.
+type int64Array []byte
+
+func (a int64Array) at(i int) int {
+	return int(int64(binary.LittleEndian.Uint64(a[8*i:])))
+}
+
+func (a int64Array) len() int {
+	return len(a) / 8
+}
+
+// An array of little-endian float64 values within a mapped file.
+type float64Array []byte
+
+func (a float64Array) at(i int) float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(a[8*i:]))
+}
+
+// Converts a model file written by TFIDF.Save() into a mapped model file.
+// Models that load untrained, such as those saved before training or by older
+// versions of this package, are trained first.
+func ConvertToMapped(modelPath, mappedPath string) error {
+	model, err := LoadTFIDF(modelPath)
+	if err != nil {
+		return err
+	}
+	if model.checkState() == ErrNotTrained {
+		model.Logger = NopLogger
+		model.Train()
+	}
+	return model.SaveMapped(mappedPath)
+}
+
+// Saves this model to the specified file in the format read by
+// OpenMappedTFIDF().  The attributes of the documents are not saved.  Returns
+// ErrNotTrained if the model has not been trained.
+func (me *TFIDF) SaveMapped(filePath string) error {
+	if err := me.checkState(); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := me.encodeMapped(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func (me *TFIDF) encodeMapped(w io.Writer) error {
+	// Deleted documents are left out, so the remaining ones are renumbered.
+	docIdxs := make([]int, 0, me.liveDocCount())
+	newDocIdx := make([]int, len(me.docs))
+	for docIdx := range me.docs {
+		if !me.deleted[docIdx] {
+			newDocIdx[docIdx] = len(docIdxs)
+			docIdxs = append(docIdxs, docIdx)
+		}
+	}
+
+	docOrder := make([]int, len(docIdxs))
+	for i := range docOrder {
+		docOrder[i] = i
+	}
+	sort.Slice(docOrder, func(i, j int) bool {
+		return me.docs[docIdxs[docOrder[i]]].Id < me.docs[docIdxs[docOrder[j]]].Id
+	})
+
+	termIds := make([]int, 0, len(me.idf))
+	for termId := range me.idf {
+		termIds = append(termIds, termId)
+	}
+	for termId := range me.index {
+		if _, found := me.idf[termId]; !found {
+			termIds = append(termIds, termId)
+		}
+	}
+	sort.Ints(termIds)
+
+	numDocTerms, numPostings := 0, 0
+	for _, docIdx := range docIdxs {
+		numDocTerms += len(me.docs[docIdx].TFIDF)
+	}
+	for _, postings := range me.index {
+		numPostings += len(postings)
+	}
+
+	mw := &mappedWriter{w: w}
+	mw.putUint64(binary.LittleEndian.Uint64([]byte(mappedMagic)))
+	mw.putInt(mappedVersion)
+	mw.putUint64(uint64(me.Scheme.TF) | uint64(me.Scheme.IDF)<<8 | uint64(me.Scheme.Norm)<<16)
+	mw.putFloat(me.pivot)
+	mw.putFloat(me.Slope)
+	for _, n := range []int{len(docIdxs), len(termIds), numPostings, numDocTerms} {
+		mw.putInt(n)
+	}
+
+	for _, docIdx := range docIdxs {
+		mw.putInt(me.docs[docIdx].Id)
+	}
+	for _, i := range docOrder {
+		mw.putInt(i)
+	}
+	for _, docIdx := range docIdxs {
+		mw.putFloat(me.docNorms[docIdx])
+	}
+	offset := 0
+	for _, docIdx := range docIdxs {
+		mw.putInt(offset)
+		offset += len(me.docs[docIdx].TFIDF)
+	}
+	mw.putInt(offset)
+	for _, docIdx := range docIdxs {
+		for _, term := range me.docs[docIdx].TFIDF {
+			mw.putInt(term.Id)
+		}
+	}
+	for _, docIdx := range docIdxs {
+		for _, term := range me.docs[docIdx].TFIDF {
+			mw.putFloat(term.Value)
+		}
+	}
+
+	for _, termId := range termIds {
+		mw.putInt(termId)
+	}
+	for _, termId := range termIds {
+		mw.putFloat(me.idf[termId])
+	}
+	offset = 0
+	for _, termId := range termIds {
+		mw.putInt(offset)
+		offset += len(me.index[termId])
+	}
+	mw.putInt(offset)
+	for _, termId := range termIds {
+		for _, p := range me.index[termId] {
+			mw.putInt(newDocIdx[p.docIdx])
+		}
+	}
+	for _, termId := range termIds {
+		for _, p := range me.index[termId] {
+			mw.putFloat(p.weight)
+		}
+	}
+
+	return mw.err
+}
+
+// Writes little-endian 64-bit values, keeping track of the first error.
+type mappedWriter struct {
+	w   io.Writer
+	buf [8]byte
+	err error
+}
+
+func (me *mappedWriter) putUint64(v uint64) {
+	if me.err == nil {
+		binary.LittleEndian.PutUint64(me.buf[:], v)
+		_, me.err = me.w.Write(me.buf[:])
+	}
+}
+
+func (me *mappedWriter) putInt(v int) {
+	me.putUint64(uint64(int64(v)))
+}
+
+func (me *mappedWriter) putFloat(v float64) {
+	me.putUint64(math.Float64bits(v))
+}
+
+// Opens a model file written by TFIDF.SaveMapped().  The file is memory-mapped
+// where the platform supports it, and read into memory otherwise.  Call Close()
+// once the model is no longer needed.
+func OpenMappedTFIDF(filePath string) (*MappedTFIDF, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, unmap, err := mapFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	model, err := newMappedTFIDF(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	model.unmap = unmap
+	return model, nil
+}
+
+func newMappedTFIDF(data []byte) (*MappedTFIDF, error) {
+	if len(data) < mappedHeaderSize || string(data[:8]) != mappedMagic {
+		return nil, ErrInvalidMappedFile
+	}
+
+	header := int64Array(data[:mappedHeaderSize])
+	if version := header.at(1); version != mappedVersion {
+		return nil, fmt.Errorf("tfidf: unsupported mapped model file version %v", version)
+	}
+
+	scheme := uint64(header.at(2))
+	numDocs, numTerms, numPostings, numDocTerms := header.at(5), header.at(6), header.at(7), header.at(8)
+	for _, n := range []int{numDocs, numTerms, numPostings, numDocTerms} {
+		if n < 0 || n > len(data)/8 {
+			return nil, ErrInvalidMappedFile
+		}
+	}
+	numValues := 4*numDocs + 1 + 2*numDocTerms + 3*numTerms + 1 + 2*numPostings
+	if len(data) != mappedHeaderSize+8*numValues {
+		return nil, ErrInvalidMappedFile
+	}
+
+	offset := mappedHeaderSize
+	next := func(n int) []byte {
+		section := data[offset : offset+8*n : offset+8*n]
+		offset += 8 * n
+		return section
+	}
+
+	model := &MappedTFIDF{
+		data:           data,
+		scheme:         SMART{TF: byte(scheme), IDF: byte(scheme >> 8), Norm: byte(scheme >> 16)},
+		pivot:          float64Array(data[24:]).at(0),
+		slope:          float64Array(data[32:]).at(0),
+		docIds:         next(numDocs),
+		docOrder:       next(numDocs),
+		docNorms:       next(numDocs),
+		docOffsets:     next(numDocs + 1),
+		docTermIds:     next(numDocTerms),
+		docWeights:     next(numDocTerms),
+		termIds:        next(numTerms),
+		termIdfs:       next(numTerms),
+		postingOffsets: next(numTerms + 1),
+		postingDocs:    next(numPostings),
+		postingWeights: next(numPostings),
+		accumulators:   newAccumulatorPool(numDocs),
+	}
+	if err := model.scheme.validate(); err != nil {
+		return nil, ErrInvalidMappedFile
+	}
+	return model, nil
+}
+
+// Checks that every offset and document index within the model is in bounds.
+// Returns ErrInvalidMappedFile if any of them is not.  OpenMappedTFIDF() only
+// checks the header and the size of the file, since this reads the whole file,
+// so call Validate() before querying a file that may be corrupt: out-of-bounds
+// values cause queries to panic.
+func (me *MappedTFIDF) Validate() error {
+	if !isValidOffsets(me.docOffsets, me.docTermIds.len()) ||
+		!isValidOffsets(me.postingOffsets, me.postingDocs.len()) {
+		return ErrInvalidMappedFile
+	}
+
+	numDocs := me.docIds.len()
+	for _, docIdxs := range []int64Array{me.docOrder, me.postingDocs} {
+		for i := 0; i < docIdxs.len(); i++ {
+			if docIdx := docIdxs.at(i); docIdx < 0 || docIdx >= numDocs {
+				return ErrInvalidMappedFile
+			}
+		}
+	}
+	return nil
+}
+
+// Returns true if the offsets start at 0, never decrease, and end at n.
+func isValidOffsets(offsets int64Array, n int) bool {
+	prev := 0
+	for i := 0; i < offsets.len(); i++ {
+		offset := offsets.at(i)
+		if offset < prev || offset > n {
+			return false
+		}
+		prev = offset
+	}
+	return offsets.at(0) == 0 && prev == n
+}
+
+// Releases the mapped file.  The model must not be used afterwards.
+func (me *MappedTFIDF) Close() error {
+	me.data = nil
+	if me.unmap == nil {
+		return nil
+	}
+	unmap := me.unmap
+	me.unmap = nil
+	return unmap()
+}
+
+// Returns the number of documents in this model.
+func (me *MappedTFIDF) DocCount() int {
+	return me.docIds.len()
+}
+
+// Returns the weighted (TF-IDF) vector of the document with the specified Id,
+// or false if the model contains no such document.
+func (me *MappedTFIDF) DocVector(docId int) (vectors.SparseVector, bool) {
+	n := me.docOrder.len()
+	i := sort.Search(n, func(i int) bool { return me.docIds.at(me.docOrder.at(i)) >= docId })
+	if i == n || me.docIds.at(me.docOrder.at(i)) != docId {
+		return nil, false
+	}
+
+	docIdx := me.docOrder.at(i)
+	start, end := me.docOffsets.at(docIdx), me.docOffsets.at(docIdx+1)
+	vec := make(vectors.SparseVector, end-start)
+	for j := range vec {
+		vec[j] = vectors.Element{Id: me.docTermIds.at(start + j), Value: me.docWeights.at(start + j)}
+	}
+	return vec, true
+}
+
+// See TFIDF.SimilarDocsForText().
+func (me *MappedTFIDF) SimilarDocsForText(query vectors.SparseVector) []ScoredItem {
+	rankedDocs, err := me.TrySimilarDocsForText(query)
+	if err != nil {
+		return []ScoredItem{}
+	}
+	return rankedDocs
+}
+
+// See TFIDF.TrySimilarDocsForText().
+func (me *MappedTFIDF) TrySimilarDocsForText(query vectors.SparseVector) ([]ScoredItem, error) {
+	queryTFIDF, err := me.weighQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return me.rankDocs(queryTFIDF), nil
+}
+
+// See TFIDF.Search().  Unlike TFIDF.Search(), the full ranking is calculated
+// regardless of opts.K.
+func (me *MappedTFIDF) Search(query vectors.SparseVector, opts QueryOptions) []ScoredItem {
+	rankedDocs, err := me.TrySearch(query, opts)
+	if err != nil {
+		return []ScoredItem{}
+	}
+	return rankedDocs
+}
+
+// See TFIDF.TrySearch().
+func (me *MappedTFIDF) TrySearch(query vectors.SparseVector, opts QueryOptions) ([]ScoredItem, error) {
+	queryTFIDF, err := me.weighQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var filter func(docIdx int) bool
+	if opts.Filter != nil {
+		if opts.Filter.usesAttributes() {
+			return nil, ErrNoAttributes
+		}
+		compiled, noAttrs := opts.Filter.compile(), &Attributes{}
+		filter = func(docIdx int) bool {
+			return compiled.matches(me.docIds.at(docIdx), noAttrs)
+		}
+	}
+
+	rankedDocs := limitResults(me.rankMatchingDocs(queryTFIDF, filter), opts)
+	attachTopTerms(rankedDocs, queryTFIDF, opts, func(docId int) vectors.SparseVector {
+		docTFIDF, _ := me.DocVector(docId)
+		return docTFIDF
+	})
+	return rankedDocs, nil
+}
+
+// Returns the position of the specified term within me.termIds, or false if
+// the model does not contain it.
+func (me *MappedTFIDF) findTerm(termId int) (int, bool) {
+	n := me.termIds.len()
+	i := sort.Search(n, func(i int) bool { return me.termIds.at(i) >= termId })
+	return i, i < n && me.termIds.at(i) == termId
+}
+
+// Weighs the specified query vector (see TFIDF.weighQuery()).
+func (me *MappedTFIDF) weighQuery(query vectors.SparseVector) (vectors.SparseVector, error) {
+	if len(query) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	idfs := make(sparseHashVector, len(query))
+	for _, term := range query {
+		if i, found := me.findTerm(term.Id); found {
+			idfs[term.Id] = me.termIdfs.at(i)
+		}
+	}
+
+	queryTFIDF := me.scheme.weigh(query, idfs, me.pivot, me.slope)
+	if vectors.Norm(queryTFIDF) == 0 {
+		return nil, ErrZeroNorm
+	}
+	return queryTFIDF, nil
+}
+
+// Ranks every non-empty document in the model against the specified weighted
+// query vector (see TFIDF.rankDocs()).
+func (me *MappedTFIDF) rankDocs(queryTFIDF vectors.SparseVector) []ScoredItem {
+	acc := me.accumulateMatching(queryTFIDF, nil)
+	defer me.accumulators.Put(acc)
+	defer acc.reset()
+
+	normQueryTFIDF := vectors.Norm(queryTFIDF)
+	rankedDocs := make([]ScoredItem, 0, len(acc.touched))
+	unmatched := []ScoredItem{}
+	touched := acc.touched
+	for docIdx, n := 0, me.docIds.len(); docIdx < n; docIdx++ {
+		var score float64
+		if len(touched) > 0 && touched[0] == docIdx {
+			score = me.scheme.score(acc.dots[docIdx], normQueryTFIDF, me.docNorms.at(docIdx))
+			touched = touched[1:]
+		} else if me.docOffsets.at(docIdx) == me.docOffsets.at(docIdx+1) {
+			continue
+		}
+
+		item := ScoredItem{Id: me.docIds.at(docIdx), Score: score}
+		if score > 0 {
+			rankedDocs = append(rankedDocs, item)
+		} else {
+			unmatched = append(unmatched, item)
+		}
+	}
+
+	sort.Stable(byScore(rankedDocs))
+	sort.Stable(byScore(unmatched))
+	return append(rankedDocs, unmatched...)
+}
+
+// Ranks the documents accepted by the filter (all documents if the filter is
+// nil) that have a score > 0 (see TFIDF.rankMatchingDocs()).
+func (me *MappedTFIDF) rankMatchingDocs(queryTFIDF vectors.SparseVector, filter func(docIdx int) bool) []ScoredItem {
+	acc := me.accumulateMatching(queryTFIDF, filter)
+	defer me.accumulators.Put(acc)
+	defer acc.reset()
+
+	normQueryTFIDF := vectors.Norm(queryTFIDF)
+	rankedDocs := make([]ScoredItem, 0, len(acc.touched))
+	for _, docIdx := range acc.touched {
+		score := me.scheme.score(acc.dots[docIdx], normQueryTFIDF, me.docNorms.at(docIdx))
+		if score > 0 {
+			rankedDocs = append(rankedDocs, ScoredItem{Id: me.docIds.at(docIdx), Score: score})
+		}
+	}
+
+	sort.Stable(byScore(rankedDocs))
+	return rankedDocs
+}
+
+// Accumulates the dot products of the specified weighted query vector with the
+// documents accepted by the filter.  The touched documents are sorted by
+// docIdx.
+func (me *MappedTFIDF) accumulateMatching(queryTFIDF vectors.SparseVector, filter func(docIdx int) bool) *accumulator {
+	acc := me.accumulators.Get().(*accumulator)
+	acc.accept = filter
+
+	for _, term := range queryTFIDF {
+		i, found := me.findTerm(term.Id)
+		if !found {
+			continue
+		}
+		for j, end := me.postingOffsets.at(i), me.postingOffsets.at(i+1); j < end; j++ {
+			acc.add(me.postingDocs.at(j), term.Value*me.postingWeights.at(j))
+		}
+	}
+	sort.Ints(acc.touched)
+	return acc
+}
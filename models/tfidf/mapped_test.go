@@ -0,0 +1,209 @@
+package tfidf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// Verifies that a mapped model returns exactly the same results as the model it
+// was created from.
+func TestMappedTFIDF_matchesModel(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	corpus := makeRandomCorpus(rng, 400, 1500, 25)
+
+	dataFile := "/tmp/gosim_TestMappedTFIDF_matchesModel.dat"
+	defer os.Remove(dataFile)
+
+	for _, scheme := range []SMART{{}, {TF: 'l', IDF: 't', Norm: 'c'}, {TF: 'a', IDF: 'p', Norm: 'u'}} {
+		// The documents are added in decreasing Id order, so that docOrder is
+		// not the identity.
+		model := newUntrainedTestModel(scheme, nil)
+		model.StopWordThreshold = 0.5
+		model.MaxStaleUpdates = 100
+		for docId := len(corpus) - 1; docId >= 0; docId-- {
+			model.AddDoc(docId, corpus[docId])
+		}
+		model.Train()
+		model.RemoveDoc(10)
+		model.AddDoc(1000, corpus[10])
+
+		assert.Nil(t, model.SaveMapped(dataFile))
+		mapped, err := OpenMappedTFIDF(dataFile)
+		assert.Nil(t, err)
+		assert.Equal(t, len(corpus), mapped.DocCount())
+		assert.Nil(t, mapped.Validate())
+
+		for i := 0; i < 30; i++ {
+			query := makeRandomCorpus(rng, 1, 1500, 6)[0]
+			assert.Equal(t, model.SimilarDocsForText(query), mapped.SimilarDocsForText(query), scheme.String())
+
+			opts := QueryOptions{K: 1 + rng.Intn(10), Offset: rng.Intn(3)}
+			assert.Equal(t, model.Search(query, opts), mapped.Search(query, opts), scheme.String())
+		}
+
+		for _, docId := range []int{0, 11, 399, 1000} {
+			vec, found := mapped.DocVector(docId)
+			assert.True(t, found)
+			assert.Equal(t, model.docs[model.docIndex[docId]].TFIDF, vec)
+		}
+		_, found := mapped.DocVector(10)
+		assert.False(t, found)
+
+		assert.Nil(t, mapped.Close())
+		assert.Nil(t, mapped.Close())
+	}
+}
+
+func TestMappedTFIDF_queryErrors(t *testing.T) {
+	model := newTestModel(SMART{}, []vectors.SparseVector{{{Id: 1, Value: 1}}})
+
+	var buf bytes.Buffer
+	assert.Nil(t, model.encodeMapped(&buf))
+	mapped, err := newMappedTFIDF(buf.Bytes())
+	assert.Nil(t, err)
+
+	_, err = mapped.TrySimilarDocsForText(vectors.SparseVector{})
+	assert.Equal(t, ErrEmptyQuery, err)
+	_, err = mapped.TrySearch(vectors.SparseVector{{Id: 99, Value: 1}}, QueryOptions{})
+	assert.Equal(t, ErrZeroNorm, err)
+	assert.Equal(t, []ScoredItem{}, mapped.SimilarDocsForText(vectors.SparseVector{{Id: 99, Value: 1}}))
+	assert.Equal(t, []ScoredItem{{Id: 0, Score: 1}}, mapped.SimilarDocsForText(vectors.SparseVector{{Id: 1, Value: 1}}))
+}
+
+func TestConvertToMapped(t *testing.T) {
+	model := newUntrainedTestModel(SMART{}, []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 2}},
+		{{Id: 2, Value: 1}},
+	})
+
+	modelFile := "/tmp/gosim_TestConvertToMapped.dat"
+	mappedFile := "/tmp/gosim_TestConvertToMapped.map"
+	defer os.Remove(modelFile)
+	defer os.Remove(mappedFile)
+
+	// A model that was saved before training is trained first.
+	assert.Nil(t, model.Save(modelFile))
+	assert.Nil(t, ConvertToMapped(modelFile, mappedFile))
+
+	model.Train()
+	assertConvertedModel(t, model, mappedFile)
+
+	assert.Nil(t, model.Save(modelFile))
+	assert.Nil(t, ConvertToMapped(modelFile, mappedFile))
+	assertConvertedModel(t, model, mappedFile)
+}
+
+// Verifies that files written by versions of this package that did not save
+// the training are trained before they are converted.
+func TestConvertToMapped_legacyFormat(t *testing.T) {
+	modelFile := "/tmp/gosim_TestConvertToMapped_legacyFormat.dat"
+	mappedFile := "/tmp/gosim_TestConvertToMapped_legacyFormat.map"
+	defer os.Remove(modelFile)
+	defer os.Remove(mappedFile)
+
+	docs := []Document{
+		{Id: 0, TF: vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 2}}},
+		{Id: 1, TF: vectors.SparseVector{{Id: 2, Value: 1}}},
+	}
+	file, err := os.Create(modelFile)
+	assert.Nil(t, err)
+	encoder := gob.NewEncoder(file)
+	for _, value := range []interface{}{1.0, len(docs), &docs[0], &docs[1]} {
+		assert.Nil(t, encoder.Encode(value))
+	}
+	file.Close()
+	assert.Nil(t, ConvertToMapped(modelFile, mappedFile))
+
+	model := newTestModel(SMART{}, []vectors.SparseVector{docs[0].TF, docs[1].TF})
+	assertConvertedModel(t, model, mappedFile)
+}
+
+// Verifies that the specified mapped model file returns the same results as
+// the model.
+func assertConvertedModel(t *testing.T, model *TFIDF, mappedFile string) {
+	mapped, err := OpenMappedTFIDF(mappedFile)
+	assert.Nil(t, err)
+	defer mapped.Close()
+
+	for _, query := range []vectors.SparseVector{{{Id: 1, Value: 1}}, {{Id: 2, Value: 1}}} {
+		assert.Equal(t, model.SimilarDocsForText(query), mapped.SimilarDocsForText(query))
+	}
+}
+
+func TestOpenMappedTFIDF_invalidFile(t *testing.T) {
+	model := newTestModel(SMART{}, []vectors.SparseVector{{{Id: 1, Value: 1}}})
+
+	var buf bytes.Buffer
+	assert.Nil(t, model.encodeMapped(&buf))
+	data := buf.Bytes()
+
+	dataFile := "/tmp/gosim_TestOpenMappedTFIDF_invalidFile.dat"
+	defer os.Remove(dataFile)
+
+	for _, invalid := range [][]byte{{}, data[:mappedHeaderSize], data[:len(data)-8], []byte("not a mapped model file")} {
+		assert.Nil(t, ioutil.WriteFile(dataFile, invalid, 0644))
+		_, err := OpenMappedTFIDF(dataFile)
+		assert.Equal(t, ErrInvalidMappedFile, err)
+	}
+
+	_, err := OpenMappedTFIDF("/a/b/c/nonexistent-file-xxxxxxxxxx.dat")
+	assert.NotNil(t, err)
+}
+
+// Verifies that out-of-bounds offsets and document indexes are reported by
+// Validate().
+func TestMappedTFIDF_Validate(t *testing.T) {
+	model := newTestModel(SMART{}, []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}},
+		{{Id: 2, Value: 1}},
+		{{Id: 3, Value: 1}},
+	})
+
+	var buf bytes.Buffer
+	assert.Nil(t, model.encodeMapped(&buf))
+
+	corruptions := map[string]func(mapped *MappedTFIDF){
+		"docOffsets not starting at 0": func(mapped *MappedTFIDF) { putInt64(mapped.docOffsets, 0, 1) },
+		"decreasing docOffsets":        func(mapped *MappedTFIDF) { putInt64(mapped.docOffsets, 2, 0) },
+		"docOffsets out of bounds":     func(mapped *MappedTFIDF) { putInt64(mapped.docOffsets, 1, 100) },
+		"postingOffsets out of bounds": func(mapped *MappedTFIDF) { putInt64(mapped.postingOffsets, 3, 5) },
+		"negative postingOffsets":      func(mapped *MappedTFIDF) { putInt64(mapped.postingOffsets, 1, -1) },
+		"postingDocs out of bounds":    func(mapped *MappedTFIDF) { putInt64(mapped.postingDocs, 0, 3) },
+		"negative docOrder":            func(mapped *MappedTFIDF) { putInt64(mapped.docOrder, 1, -1) },
+	}
+	for name, corrupt := range corruptions {
+		data := append([]byte{}, buf.Bytes()...)
+		mapped, err := newMappedTFIDF(data)
+		assert.Nil(t, err, name)
+		assert.Nil(t, mapped.Validate(), name)
+		corrupt(mapped)
+
+		// Opening the file only checks its header and size.
+		mapped, err = newMappedTFIDF(data)
+		assert.Nil(t, err, name)
+		assert.Equal(t, ErrInvalidMappedFile, mapped.Validate(), name)
+	}
+}
+
+func putInt64(a int64Array, i, value int) {
+	binary.LittleEndian.PutUint64(a[8*i:], uint64(int64(value)))
+}
+
+func BenchmarkMappedTFIDF_rankDocs(b *testing.B) {
+	model, queries := makeBenchmarkModel(20000)
+	var buf bytes.Buffer
+	model.encodeMapped(&buf)
+	mapped, _ := newMappedTFIDF(buf.Bytes())
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		mapped.rankDocs(queries[n%len(queries)])
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package tfidf
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// Reads the specified file into memory, on platforms where memory-mapping is
+// not supported.  Returns the file's bytes and a no-op function.
+func mapFile(file *os.File) ([]byte, func() error, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,47 @@
+package tfidf
+
+import (
+	"sync"
+)
+
+// Splits the range [0..n) into (at most) the specified number of contiguous
+// chunks, and calls fn(worker, lo, hi) for each chunk [lo..hi) on its own
+// goroutine.  Returns after all calls to fn have returned.  If workers < 2,
+// fn(0, 0, n) is called on the calling goroutine.
+func parallelFor(n, workers int, fn func(worker, lo, hi int)) {
+	if workers < 2 || n < 2 {
+		fn(0, 0, n)
+		return
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var wg sync.WaitGroup
+	chunkSize := (n + workers - 1) / workers
+	for worker, lo := 0, 0; lo < n; worker, lo = worker+1, lo+chunkSize {
+		hi := lo + chunkSize
+		if hi > n {
+			hi = n
+		}
+
+		wg.Add(1)
+		go func(worker, lo, hi int) {
+			defer wg.Done()
+			fn(worker, lo, hi)
+		}(worker, lo, hi)
+	}
+	wg.Wait()
+}
+
+// Returns the number of chunks that parallelFor(n, workers, ...) will use.
+func numChunks(n, workers int) int {
+	if workers < 2 || n < 2 {
+		return 1
+	}
+	if workers > n {
+		workers = n
+	}
+	chunkSize := (n + workers - 1) / workers
+	return (n + chunkSize - 1) / chunkSize
+}
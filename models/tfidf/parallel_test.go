@@ -0,0 +1,114 @@
+package tfidf
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestParallelFor(t *testing.T) {
+	for _, tc := range []struct{ n, workers int }{{0, 4}, {1, 4}, {10, 1}, {10, 3}, {10, 4}, {3, 8}, {100, 7}} {
+		var mu sync.Mutex
+		visits := make([]int, tc.n)
+		workersSeen := map[int]bool{}
+
+		parallelFor(tc.n, tc.workers, func(worker, lo, hi int) {
+			mu.Lock()
+			defer mu.Unlock()
+			workersSeen[worker] = true
+			for i := lo; i < hi; i++ {
+				visits[i]++
+			}
+		})
+
+		for i := range visits {
+			assert.Equal(t, 1, visits[i], "n=%v workers=%v", tc.n, tc.workers)
+		}
+		assert.Equal(t, numChunks(tc.n, tc.workers), len(workersSeen), "n=%v workers=%v", tc.n, tc.workers)
+		for worker := range workersSeen {
+			assert.True(t, worker < numChunks(tc.n, tc.workers))
+		}
+	}
+}
+
+// Verifies that training and querying with multiple workers produces exactly
+// the same model and results as the serial path.
+func TestTFIDF_parallelMatchesSerial(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	corpus := makeRandomCorpus(rng, 1000, 3000, 40)
+	queries := makeRandomCorpus(rng, 50, 3000, 8)
+
+	newModel := func(workers int) *TFIDF {
+		model := NewTFIDF()
+		model.StopWordThreshold = 0.5
+		model.MinDocFreq = 2
+		model.Workers = workers
+		for docId, doc := range corpus {
+			model.AddDoc(docId, doc)
+		}
+		model.Train()
+		return model
+	}
+
+	serial := newModel(1)
+	for _, workers := range []int{2, 3, 8} {
+		parallel := newModel(workers)
+		assert.Equal(t, serial.docs, parallel.docs)
+		assert.Equal(t, serial.idf, parallel.idf)
+		assert.Equal(t, serial.index, parallel.index)
+		assert.Equal(t, serial.docNorms, parallel.docNorms)
+
+		for _, query := range queries {
+			assert.Equal(t, serial.SimilarDocsForText(query), parallel.SimilarDocsForText(query))
+			for _, opts := range []QueryOptions{{K: 10}, {K: 1}, {K: 5, Offset: 3, MinScore: 0.05}, {K: 10, Filter: &Filter{DenyIds: []int{1, 2, 3}}}} {
+				assert.Equal(t, serial.Search(query, opts), parallel.Search(query, opts))
+			}
+		}
+	}
+}
+
+func BenchmarkTFIDF_Train(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	corpus := makeRandomCorpus(rng, 20000, 50000, 100)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%v", workers), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				model := NewTFIDF()
+				model.Workers = workers
+				for docId, doc := range corpus {
+					model.AddDoc(docId, doc)
+				}
+				model.Train()
+			}
+		})
+	}
+}
+
+func BenchmarkTFIDF_rankDocs_workers(b *testing.B) {
+	model, queries := makeBenchmarkModel(100000)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%v", workers), func(b *testing.B) {
+			model.Workers = workers
+			for n := 0; n < b.N; n++ {
+				model.rankDocs(queries[n%len(queries)])
+			}
+		})
+	}
+}
+
+func BenchmarkTFIDF_Search_workers(b *testing.B) {
+	model, queries := makeBenchmarkModel(100000)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%v", workers), func(b *testing.B) {
+			model.Workers = workers
+			for n := 0; n < b.N; n++ {
+				model.search(queries[n%len(queries)], QueryOptions{K: 10})
+			}
+		})
+	}
+}
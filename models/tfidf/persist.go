@@ -0,0 +1,217 @@
+package tfidf
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Identifies a model file written by Save().  Files written by older versions
+// of this package start with the StopWordThreshold instead.
+const fileMagic = "gosim/tfidf"
+
+// The version of the model file format written by Save().
+const fileVersion = 1
+
+// The state of a TFIDF model, other than its documents, as saved to file.
+type savedModel struct {
+	StopWordThreshold float64
+	StopWordIds       []int
+	ProtectedTermIds  []int
+	MinDocFreq        float64
+	MaxDocFreq        float64
+	MaxVocabulary     int
+	MaxStaleUpdates   int
+	MaxStaleFraction  float64
+	Scheme            SMART
+	Pivot             float64
+	Slope             float64
+
+	// The remaining fields are only meaningful if Trained is true.
+	Trained        bool
+	NeedsRecalc    bool
+	StaleUpdates   int
+	EffectivePivot float64
+	IDF            map[int]float64
+	DocFreqs       map[int]int
+	Stats          Stats
+}
+
+// Saves this model to the specified file.  Along with the documents, the file
+// holds the training parameters, and, if the model has been trained, the IDF
+// values and corpus stats, so that the loaded model can be queried right away.
+func (me *TFIDF) Save(filePath string) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if err := me.encode(writer); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func (me *TFIDF) encode(w io.Writer) error {
+	saved := savedModel{
+		StopWordThreshold: me.StopWordThreshold,
+		StopWordIds:       me.StopWordIds,
+		ProtectedTermIds:  me.ProtectedTermIds,
+		MinDocFreq:        me.MinDocFreq,
+		MaxDocFreq:        me.MaxDocFreq,
+		MaxVocabulary:     me.MaxVocabulary,
+		MaxStaleUpdates:   me.MaxStaleUpdates,
+		MaxStaleFraction:  me.MaxStaleFraction,
+		Scheme:            me.Scheme,
+		Pivot:             me.Pivot,
+		Slope:             me.Slope,
+		Trained:           me.trained,
+		NeedsRecalc:       me.needsRecalc,
+		StaleUpdates:      me.staleUpdates,
+		EffectivePivot:    me.pivot,
+		IDF:               me.idf,
+		DocFreqs:          me.df,
+		Stats:             me.stats,
+	}
+
+	encoder := gob.NewEncoder(w)
+	for _, value := range []interface{}{fileMagic, fileVersion, &saved, me.liveDocCount()} {
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < len(me.docs); i++ {
+		if !me.deleted[i] {
+			if err := encoder.Encode(&me.docs[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Loads a TFIDF model from a saved image on file.  Files written by older
+// versions of this package can still be loaded, but the resulting model needs
+// to be trained before it can be queried.
+func LoadTFIDF(filePath string) (*TFIDF, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+
+	// Older files start with a float64, which cannot be decoded as a string.
+	var magic string
+	if err := decoder.Decode(&magic); err != nil || magic != fileMagic {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return decodeLegacy(gob.NewDecoder(bufio.NewReader(file)))
+	}
+
+	var version int
+	if err := decoder.Decode(&version); err != nil {
+		return nil, err
+	}
+	if version > fileVersion {
+		return nil, fmt.Errorf("tfidf: unsupported model file version %v", version)
+	}
+
+	var saved savedModel
+	if err := decoder.Decode(&saved); err != nil {
+		return nil, err
+	}
+
+	docs, err := decodeDocs(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	model := newLoadedModel(docs)
+	model.StopWordThreshold = saved.StopWordThreshold
+	model.StopWordIds = saved.StopWordIds
+	model.ProtectedTermIds = saved.ProtectedTermIds
+	model.MinDocFreq = saved.MinDocFreq
+	model.MaxDocFreq = saved.MaxDocFreq
+	model.MaxVocabulary = saved.MaxVocabulary
+	model.MaxStaleUpdates = saved.MaxStaleUpdates
+	model.MaxStaleFraction = saved.MaxStaleFraction
+	model.Scheme = saved.Scheme
+	model.Pivot = saved.Pivot
+	model.Slope = saved.Slope
+
+	if saved.Trained {
+		model.trained = true
+		model.needsRecalc = saved.NeedsRecalc
+		model.staleUpdates = saved.StaleUpdates
+		model.pivot = saved.EffectivePivot
+		model.idf = make(sparseHashVector, len(saved.IDF))
+		for termId, idf := range saved.IDF {
+			model.idf[termId] = idf
+		}
+		model.df = make(map[int]int, len(saved.DocFreqs))
+		for termId, df := range saved.DocFreqs {
+			model.df[termId] = df
+		}
+		model.stats = saved.Stats
+		model.rebuildIndex()
+	}
+
+	return model, nil
+}
+
+// Decodes a model file written by older versions of this package.
+func decodeLegacy(decoder *gob.Decoder) (*TFIDF, error) {
+	var stopWordThreshold float64
+	if err := decoder.Decode(&stopWordThreshold); err != nil {
+		return nil, err
+	}
+
+	docs, err := decodeDocs(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	model := newLoadedModel(docs)
+	model.StopWordThreshold = stopWordThreshold
+	return model, nil
+}
+
+// Decodes the document count, followed by the documents.
+func decodeDocs(decoder *gob.Decoder) ([]Document, error) {
+	var docCount int
+	if err := decoder.Decode(&docCount); err != nil {
+		return nil, err
+	}
+
+	docs := make([]Document, 0, docCount)
+	for i := 0; i < docCount; i++ {
+		var doc Document
+		if err := decoder.Decode(&doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// Creates an untrained model that holds the specified documents.
+func newLoadedModel(docs []Document) *TFIDF {
+	model := NewTFIDF()
+	model.docs = docs
+	model.docIndex = make(map[int]int, len(docs))
+	for docIdx, doc := range docs {
+		model.docIndex[doc.Id] = docIdx
+	}
+	return model
+}
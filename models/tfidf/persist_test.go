@@ -0,0 +1,130 @@
+package tfidf
+
+import (
+	"encoding/gob"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestSaveAndLoadTFIDF_trained(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	corpus := makeRandomCorpus(rng, 300, 1000, 20)
+
+	model := NewTFIDF()
+	model.StopWordThreshold = 0.3
+	model.StopWordIds = []int{7}
+	model.MinDocFreq = 2
+	model.MaxStaleUpdates = 10
+	model.MaxStaleFraction = 0.05
+	model.Scheme = SMART{TF: 'l', IDF: 't', Norm: 'u'}
+	model.Slope = 0.3
+	for docId, doc := range corpus[:250] {
+		model.AddDoc(docId, doc)
+	}
+	stats := model.Train()
+	model.AddDoc(250, corpus[250])
+	model.RemoveDoc(3)
+
+	dataFile := "/tmp/gosim_TestSaveAndLoadTFIDF_trained.dat"
+	defer os.Remove(dataFile)
+	assert.Nil(t, model.Save(dataFile))
+
+	loaded, err := LoadTFIDF(dataFile)
+	assert.Nil(t, err)
+	assert.Equal(t, model.StopWordIds, loaded.StopWordIds)
+	assert.Equal(t, model.MinDocFreq, loaded.MinDocFreq)
+	assert.Equal(t, model.MaxStaleUpdates, loaded.MaxStaleUpdates)
+	assert.Equal(t, model.MaxStaleFraction, loaded.MaxStaleFraction)
+	assert.Equal(t, model.Scheme, loaded.Scheme)
+	assert.Equal(t, model.Slope, loaded.Slope)
+	assert.Equal(t, model.pivot, loaded.pivot)
+	assert.Equal(t, model.idf, loaded.idf)
+	assert.Equal(t, 2, loaded.staleUpdates)
+	assert.Equal(t, stats, loaded.Stats())
+
+	// The loaded model can be queried without retraining.
+	for i := 0; i < 20; i++ {
+		query := makeRandomCorpus(rng, 1, 1000, 5)[0]
+		assert.Equal(t, model.SimilarDocsForText(query), loaded.SimilarDocsForText(query))
+		assert.Equal(t, model.Search(query, QueryOptions{K: 3}), loaded.Search(query, QueryOptions{K: 3}))
+	}
+
+	// ... and updated.
+	assert.Equal(t, ErrDuplicateDocId, loaded.AddDoc(250, corpus[250]))
+	assert.Equal(t, ErrUnknownDocId, loaded.RemoveDoc(3))
+	for docId := 251; docId < len(corpus); docId++ {
+		model.AddDoc(docId, corpus[docId])
+		assert.Nil(t, loaded.AddDoc(docId, corpus[docId]))
+	}
+	assert.Equal(t, model.idf, loaded.idf)
+}
+
+func TestSaveAndLoadTFIDF_untrained(t *testing.T) {
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	model.AddDoc(1, vectors.SparseVector{{Id: 1, Value: 1}})
+
+	dataFile := "/tmp/gosim_TestSaveAndLoadTFIDF_untrained.dat"
+	defer os.Remove(dataFile)
+	assert.Nil(t, model.Save(dataFile))
+
+	loaded, err := LoadTFIDF(dataFile)
+	assert.Nil(t, err)
+	_, err = loaded.TrySimilarDocsForText(vectors.SparseVector{{Id: 1, Value: 1}})
+	assert.Equal(t, ErrNotTrained, err)
+
+	loaded.Train()
+	assert.Equal(t, []int{1}, scoredItemIds(loaded.SimilarDocsForText(vectors.SparseVector{{Id: 1, Value: 1}})))
+}
+
+// Verifies that files written by older versions of this package (with and
+// without the weighting scheme) can still be loaded.
+func TestLoadTFIDF_legacyFormat(t *testing.T) {
+	doc := Document{
+		Id:    123,
+		TF:    vectors.SparseVector{{Id: 1, Value: 10}, {Id: 2, Value: 20}},
+		TFIDF: vectors.SparseVector{{Id: 1, Value: 0.5}, {Id: 2, Value: 0.25}},
+	}
+
+	dataFile := "/tmp/gosim_TestLoadTFIDF_legacyFormat.dat"
+	defer os.Remove(dataFile)
+
+	file, err := os.Create(dataFile)
+	assert.Nil(t, err)
+	encoder := gob.NewEncoder(file)
+	for _, value := range []interface{}{0.35, 1, &doc} {
+		assert.Nil(t, encoder.Encode(value))
+	}
+	file.Close()
+
+	model, err := LoadTFIDF(dataFile)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.35, model.StopWordThreshold)
+	assert.Equal(t, []Document{doc}, model.docs)
+	assert.True(t, model.needsRecalc)
+	assert.Equal(t, NewTFIDF().MaxStaleFraction, model.MaxStaleFraction)
+	assert.Equal(t, SMART{}, model.Scheme)
+	assert.Equal(t, 0.25, model.Slope)
+}
+
+func TestLoadTFIDF_unsupportedVersion(t *testing.T) {
+	dataFile := "/tmp/gosim_TestLoadTFIDF_unsupportedVersion.dat"
+	defer os.Remove(dataFile)
+
+	file, err := os.Create(dataFile)
+	assert.Nil(t, err)
+	encoder := gob.NewEncoder(file)
+	encoder.Encode(fileMagic)
+	encoder.Encode(fileVersion + 1)
+	file.Close()
+
+	_, err = LoadTFIDF(dataFile)
+	assert.EqualError(t, err, "tfidf: unsupported model file version 2")
+}
+
+func TestTFIDF_Save_error(t *testing.T) {
+	assert.NotNil(t, NewTFIDF().Save("/a/b/c/nonexistent-dir-xxxxxxxxxx/model.dat"))
+}
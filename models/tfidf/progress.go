@@ -0,0 +1,182 @@
+package tfidf
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Receives the log messages of a model.  args are alternating key/value pairs,
+// as with log/slog, whose *slog.Logger satisfies this interface.
+type Logger interface {
+	Info(msg string, args ...interface{})
+}
+
+// A Logger that discards all messages.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Info(msg string, args ...interface{}) {}
+
+// The Logger that is used by models whose Logger field is nil.
+var defaultLogger = NewLogger(log.New(os.Stderr, "[gosim] ", (log.Ldate | log.Ltime)))
+
+// Returns a Logger that writes each message to l as a single line, followed by
+// its arguments formatted as key=value pairs.
+func NewLogger(l *log.Logger) Logger {
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (me stdLogger) Info(msg string, args ...interface{}) {
+	var line strings.Builder
+	line.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&line, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&line, " %v", args[i])
+		}
+	}
+	me.l.Print(line.String())
+}
+
+// A phase of Train() whose progress is reported to TFIDF.OnProgress.
+type TrainPhase int
+
+const (
+	// Counting the document frequencies of the terms.
+	PhaseDocFrequencies TrainPhase = iota + 1
+
+	// Removing stop words and pruned terms from the document vectors.
+	PhaseFiltering
+
+	// Calculating the weighted document vectors.
+	PhaseWeighting
+
+	// Building the inverted index.
+	PhaseIndexing
+)
+
+func (p TrainPhase) String() string {
+	switch p {
+	case PhaseDocFrequencies:
+		return "DocFrequencies"
+	case PhaseFiltering:
+		return "Filtering"
+	case PhaseWeighting:
+		return "Weighting"
+	case PhaseIndexing:
+		return "Indexing"
+	default:
+		return "Unknown"
+	}
+}
+
+// The progress of a phase of Train().
+type TrainProgress struct {
+	Phase TrainPhase
+
+	// The number of documents that have been processed so far, out of Total.
+	Done  int
+	Total int
+}
+
+// The number of documents that a worker processes between progress reports
+// and cancellation checks.
+const progressBatchSize = 1024
+
+// Reports the progress of a training, and tells its workers when to stop.  A
+// nil *trainTracker reports nothing and never stops.
+type trainTracker struct {
+	ctx        context.Context
+	onProgress func(TrainProgress)
+
+	// Serializes the calls to onProgress.
+	mutex    sync.Mutex
+	progress TrainProgress
+}
+
+func newTrainTracker(ctx context.Context, onProgress func(TrainProgress)) *trainTracker {
+	return &trainTracker{ctx: ctx, onProgress: onProgress}
+}
+
+// Starts a phase that will process the specified number of documents.  Returns
+// the context's error if the training has been cancelled.
+func (me *trainTracker) startPhase(phase TrainPhase, total int) error {
+	if err := me.ctx.Err(); err != nil {
+		return err
+	}
+
+	me.mutex.Lock()
+	defer me.mutex.Unlock()
+	me.progress = TrainProgress{Phase: phase, Total: total}
+	me.report()
+	return nil
+}
+
+// Records that n more documents have been processed.  Returns false if the
+// training has been cancelled.
+func (me *trainTracker) advance(n int) bool {
+	if me == nil {
+		return true
+	}
+
+	me.mutex.Lock()
+	me.progress.Done += n
+	me.report()
+	me.mutex.Unlock()
+	return me.ctx.Err() == nil
+}
+
+// Ends the current phase.  Returns the context's error if the training has
+// been cancelled.
+func (me *trainTracker) endPhase() error {
+	if err := me.ctx.Err(); err != nil {
+		return err
+	}
+
+	me.mutex.Lock()
+	if me.progress.Done < me.progress.Total {
+		me.progress.Done = me.progress.Total
+		me.report()
+	}
+	me.mutex.Unlock()
+
+	// The context may have been cancelled by the final report.
+	return me.ctx.Err()
+}
+
+// Must be called with me.mutex held.
+func (me *trainTracker) report() {
+	if me.onProgress != nil {
+		me.onProgress(me.progress)
+	}
+}
+
+// Does what parallelFor() does, except that each worker calls fn on batches of
+// (at most) progressBatchSize items of its chunk, reporting its progress to
+// the tracker after each batch.  Workers stop early once the training has been
+// cancelled.
+func trackedParallelFor(n, workers int, tracker *trainTracker, fn func(worker, lo, hi int)) {
+	parallelFor(n, workers, func(worker, lo, hi int) {
+		for batchLo := lo; batchLo < hi; batchLo += progressBatchSize {
+			batchHi := batchLo + progressBatchSize
+			if batchHi > hi {
+				batchHi = hi
+			}
+
+			fn(worker, batchLo, batchHi)
+			if !tracker.advance(batchHi - batchLo) {
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package tfidf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"math/rand"
+	"testing"
+)
+
+// Records the messages that it receives.
+type recordingLogger struct {
+	messages []string
+}
+
+func (me *recordingLogger) Info(msg string, args ...interface{}) {
+	me.messages = append(me.messages, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func TestTFIDF_Train_logger(t *testing.T) {
+	logger := &recordingLogger{}
+	model := newProgressTestModel(100, 1)
+	model.Logger = logger
+	model.Train()
+
+	assert.True(t, len(logger.messages) > 0)
+	assert.Contains(t, logger.messages[0], "Calculating document frequencies")
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(log.New(&buf, "", 0))
+
+	logger.Info("Indexed documents", "docs", 10, "terms", 20)
+	logger.Info("Odd arguments", "docs")
+	assert.Equal(t, "Indexed documents docs=10 terms=20\nOdd arguments docs\n", buf.String())
+
+	NopLogger.Info("discarded", "docs", 10)
+}
+
+func TestTFIDF_Train_progress(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		numDocs := 3*progressBatchSize + 10
+		model := newProgressTestModel(numDocs, workers)
+
+		reports := map[TrainPhase][]TrainProgress{}
+		phases := []TrainPhase{}
+		model.OnProgress = func(p TrainProgress) {
+			if len(reports[p.Phase]) == 0 {
+				phases = append(phases, p.Phase)
+			}
+			reports[p.Phase] = append(reports[p.Phase], p)
+		}
+		model.Train()
+
+		assert.Equal(t, []TrainPhase{PhaseDocFrequencies, PhaseFiltering, PhaseWeighting, PhaseIndexing}, phases)
+		for phase, phaseReports := range reports {
+			msg := fmt.Sprintf("workers=%v, phase=%v", workers, phase)
+			assert.Equal(t, TrainProgress{Phase: phase, Total: numDocs}, phaseReports[0], msg)
+			assert.Equal(t, TrainProgress{Phase: phase, Done: numDocs, Total: numDocs}, phaseReports[len(phaseReports)-1], msg)
+			for i := 1; i < len(phaseReports); i++ {
+				assert.True(t, phaseReports[i].Done > phaseReports[i-1].Done, msg)
+			}
+		}
+
+		// Documents are reported in batches.
+		assert.True(t, len(reports[PhaseDocFrequencies]) >= 5)
+		assert.True(t, len(reports[PhaseWeighting]) >= 5)
+	}
+}
+
+func TestTFIDF_TrainContext_cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	model := newProgressTestModel(100, 1)
+	docs := append([]Document(nil), model.docs...)
+	_, err := model.TrainContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, docs, model.docs)
+	assert.Equal(t, ErrNotTrained, model.checkState())
+}
+
+func TestTFIDF_TrainContext_cancelledMidPhase(t *testing.T) {
+	for _, phase := range []TrainPhase{PhaseDocFrequencies, PhaseFiltering, PhaseWeighting, PhaseIndexing} {
+		for _, workers := range []int{1, 4} {
+			msg := fmt.Sprintf("phase=%v, workers=%v", phase, workers)
+			numDocs := 3 * progressBatchSize
+
+			// A trained model remains usable, and unchanged, when retraining
+			// is cancelled.
+			expected := newProgressTestModel(numDocs, workers)
+			expected.Train()
+			model := newProgressTestModel(numDocs, workers)
+			model.Train()
+			stats := model.Stats()
+			model.StopWordThreshold = 0.01
+
+			ctx, cancel := context.WithCancel(context.Background())
+			model.OnProgress = func(p TrainProgress) {
+				if p.Phase == phase && p.Done > 0 {
+					cancel()
+				}
+			}
+			_, err := model.TrainContext(ctx)
+			assert.Equal(t, context.Canceled, err, msg)
+			assert.Equal(t, stats, model.Stats(), msg)
+			assert.Equal(t, expected.docs, model.docs, msg)
+			assertSameRankings(t, rand.New(rand.NewSource(1)), expected, model, msg)
+
+			// Training can be resumed afterwards.
+			model.StopWordThreshold = expected.StopWordThreshold
+			_, err = model.TrainContext(context.Background())
+			assert.Nil(t, err, msg)
+			assertSameRankings(t, rand.New(rand.NewSource(1)), expected, model, msg)
+		}
+	}
+}
+
+func TestTFIDF_TrainContext_invalidScheme(t *testing.T) {
+	model := newProgressTestModel(10, 1)
+	model.Scheme = SMART{TF: 'x'}
+	_, err := model.TrainContext(context.Background())
+	assert.NotNil(t, err)
+	assert.Panics(t, func() { model.Train() })
+}
+
+func newProgressTestModel(numDocs, workers int) *TFIDF {
+	model := newUntrainedTestModel(SMART{}, makeRandomCorpus(rand.New(rand.NewSource(1)), numDocs, 1000, 20))
+	model.Workers = workers
+	model.StopWordThreshold = 0.5
+	return model
+}
@@ -0,0 +1,450 @@
+package tfidf
+
+import (
+	"container/heap"
+	"github.com/cet001/mathext/vectors"
+	"math"
+	"sort"
+)
+
+// Upper bounds are inflated by this relative amount to absorb floating point
+// rounding differences, so that pruning never discards a qualifying document.
+const boundSlack = 1e-9
+
+// Options for TFIDF.Search().
+type QueryOptions struct {
+	// The maximum number of results to return.  0 means no limit.
+	K int
+
+	// Only documents whose score is >= MinScore are returned.  Documents with a
+	// score of 0 are never returned.
+	MinScore float64
+
+	// The number of top-ranked results to skip, for pagination.  A negative
+	// Offset is treated as 0.
+	Offset int
+
+	// If > 0, the TopTerms of each result are set to the (up to) TopTerms query
+	// terms that contribute the most to its score.
+	TopTerms int
+
+	// If not nil, used to resolve the words of the TopTerms (e.g. a
+	// *gosim.Dictionary).
+	Vocabulary Vocabulary
+
+	// If not nil, only documents that match this filter are scored and
+	// returned.
+	Filter *Filter
+}
+
+// Returns the documents most similar to the specified query, in the same order
+// as SimilarDocsForText(), but bounded by opts.  Unlike SimilarDocsForText(),
+// only the documents with a score > 0 are returned.  When opts.K > 0, the query
+// is evaluated with MaxScore-style pruning: once no document outside the
+// current candidate set can make it into the top (K + Offset), the remaining
+// postings are only used to update existing candidates.
+//
+// Panics if the model has not been trained.
+func (me *TFIDF) Search(query vectors.SparseVector, opts QueryOptions) []ScoredItem {
+	rankedDocs, err := me.TrySearch(query, opts)
+	if err == ErrNotTrained {
+		panic(err.Error())
+	}
+	if err != nil {
+		return []ScoredItem{}
+	}
+	return rankedDocs
+}
+
+// Does what Search() does, but returns the same errors as
+// TrySimilarDocsForText() instead of panicking.
+func (me *TFIDF) TrySearch(query vectors.SparseVector, opts QueryOptions) ([]ScoredItem, error) {
+	queryTFIDF, err := me.weighQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	rankedDocs := me.search(queryTFIDF, opts)
+	attachTopTerms(rankedDocs, queryTFIDF, opts, func(docId int) vectors.SparseVector {
+		return me.docs[me.docIndex[docId]].TFIDF
+	})
+	return rankedDocs, nil
+}
+
+func (me *TFIDF) search(queryTFIDF vectors.SparseVector, opts QueryOptions) []ScoredItem {
+	opts = opts.normalized()
+	filter := me.docFilter(opts.Filter)
+	if opts.K <= 0 {
+		return limitResults(me.rankMatchingDocs(queryTFIDF, filter), opts)
+	}
+
+	buf := &searchBuffers{acc: me.getAccumulator()}
+	defer me.accumulators.Put(buf.acc)
+	return limitResults(me.searchTopK(queryTFIDF, opts, filter, me.Workers, buf, nil), opts)
+}
+
+// Returns a copy of these options in which a negative Offset is replaced by 0,
+// so that (K + Offset) never falls below K.
+func (me QueryOptions) normalized() QueryOptions {
+	if me.Offset < 0 {
+		me.Offset = 0
+	}
+	return me
+}
+
+// Scratch space that is reused by the queries that run on a goroutine.
+type searchBuffers struct {
+	acc *accumulator
+
+	// Used by accumulatePruned().
+	bounds    []float64
+	order     []int
+	remaining []float64
+
+	// Used by searchTopKInRange().
+	approxScores candidateHeap
+	topDocs      candidateHeap
+}
+
+// Does what search() does, on the calling goroutine only, using the specified
+// buffers.  The results are appended to rankedDocs[:0], and still need to be
+// limited by limitResults().
+func (me *TFIDF) searchWith(queryTFIDF vectors.SparseVector, opts QueryOptions, filter func(docIdx int) bool, buf *searchBuffers, rankedDocs []ScoredItem) []ScoredItem {
+	if opts.K > 0 {
+		return me.searchTopK(queryTFIDF, opts, filter, 1, buf, rankedDocs)
+	}
+
+	acc := buf.acc
+	defer acc.reset()
+	me.accumulateInto(acc, queryTFIDF, filter)
+	sort.Ints(acc.touched)
+	return me.rankTouched(acc, vectors.Norm(queryTFIDF), rankedDocs[:0])
+}
+
+// Ranks the top (opts.K + opts.Offset) documents for the query, appending them
+// to rankedDocs[:0].  If workers > 1, the corpus is split into contiguous
+// ranges of documents, whose top documents are found by separate workers (the
+// first of which uses buf) and then merged.
+func (me *TFIDF) searchTopK(queryTFIDF vectors.SparseVector, opts QueryOptions, filter func(docIdx int) bool, workers int, buf *searchBuffers, rankedDocs []ScoredItem) []ScoredItem {
+	numNeeded := opts.K + opts.Offset
+
+	var topDocs candidateHeap
+	if numChunks(len(me.docs), workers) < 2 {
+		topDocs = me.searchTopKInRange(queryTFIDF, opts, filter, buf, 0, len(me.docs))
+	} else {
+		topDocsPerWorker := make([]candidateHeap, numChunks(len(me.docs), workers))
+		parallelFor(len(me.docs), workers, func(worker, lo, hi int) {
+			workerBuf := buf
+			if worker > 0 {
+				workerBuf = &searchBuffers{acc: me.getAccumulator()}
+				defer me.accumulators.Put(workerBuf.acc)
+			}
+			topDocsPerWorker[worker] = me.searchTopKInRange(queryTFIDF, opts, filter, workerBuf, lo, hi)
+		})
+
+		topDocs = make(candidateHeap, 0, numNeeded)
+		for _, candidates := range topDocsPerWorker {
+			for _, c := range candidates {
+				topDocs.offer(c, numNeeded)
+			}
+		}
+	}
+
+	sort.Slice(topDocs, func(i, j int) bool { return topDocs[j].isWorseThan(topDocs[i]) })
+
+	rankedDocs = rankedDocs[:0]
+	for _, c := range topDocs {
+		rankedDocs = append(rankedDocs, ScoredItem{Id: me.docs[c.docIdx].Id, Score: c.score})
+	}
+	return rankedDocs
+}
+
+// Returns the top (opts.K + opts.Offset) documents for the query among the
+// documents in the range [lo..hi), as a heap (stored in buf.topDocs).
+func (me *TFIDF) searchTopKInRange(queryTFIDF vectors.SparseVector, opts QueryOptions, filter func(docIdx int) bool, buf *searchBuffers, lo, hi int) candidateHeap {
+	numNeeded := opts.K + opts.Offset
+	normQueryTFIDF := vectors.Norm(queryTFIDF)
+
+	acc := buf.acc
+	defer acc.reset()
+	acc.accept = filter
+
+	me.accumulatePruned(buf, queryTFIDF, normQueryTFIDF, numNeeded, opts.MinScore, lo, hi)
+
+	// The accumulated dot products were summed in a different order than
+	// vectors.Dot() would, so they may differ from it by a rounding error.  Use
+	// them to select the candidates, and then calculate their exact scores.
+	approxThreshold := opts.MinScore
+	if len(acc.touched) > numNeeded {
+		approxScores := buf.approxScores[:0]
+		for _, docIdx := range acc.touched {
+			score := me.score(acc.dots[docIdx], normQueryTFIDF, me.docNorms[docIdx])
+			approxScores.offer(candidate{docIdx: docIdx, score: score}, numNeeded)
+		}
+		approxThreshold = math.Max(approxThreshold, approxScores[0].score)
+		buf.approxScores = approxScores
+	}
+	approxThreshold -= math.Abs(approxThreshold) * boundSlack
+
+	topDocs := buf.topDocs[:0]
+	for _, docIdx := range acc.touched {
+		if me.score(acc.dots[docIdx], normQueryTFIDF, me.docNorms[docIdx]) < approxThreshold {
+			continue
+		}
+
+		score := me.score(vectors.Dot(queryTFIDF, me.docs[docIdx].TFIDF), normQueryTFIDF, me.docNorms[docIdx])
+		if score > 0 && score >= opts.MinScore {
+			topDocs.offer(candidate{docIdx: docIdx, score: score}, numNeeded)
+		}
+	}
+
+	buf.topDocs = topDocs
+	return topDocs
+}
+
+// Accumulates the dot products of the query with the documents in the range
+// [lo..hi), processing the query terms in decreasing order of their maximum
+// possible contribution to a score.  As soon as the sum of the contributions of the remaining terms can
+// no longer lift a new document above both minScore and the numNeeded-th best
+// (partial) score so far, documents that have not been seen yet are ignored.
+func (me *TFIDF) accumulatePruned(buf *searchBuffers, queryTFIDF vectors.SparseVector, normQueryTFIDF float64, numNeeded int, minScore float64, lo, hi int) {
+	acc := buf.acc
+	canPrune := me.nonNegativeWeights && normQueryTFIDF > 0
+	for _, term := range queryTFIDF {
+		canPrune = canPrune && term.Value >= 0
+	}
+
+	if n := len(queryTFIDF); cap(buf.bounds) < n {
+		buf.bounds = make([]float64, n)
+		buf.order = make([]int, n)
+		buf.remaining = make([]float64, n+1)
+	}
+
+	// bounds[i] -> upper bound of the contribution of query term i to any score.
+	bounds := buf.bounds[:len(queryTFIDF)]
+	order := buf.order[:len(queryTFIDF)]
+	for i, term := range queryTFIDF {
+		bounds[i] = term.Value * me.maxWeights[term.Id]
+		if me.Scheme.isLegacy() {
+			bounds[i] /= normQueryTFIDF
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return bounds[order[i]] > bounds[order[j]] })
+
+	// remaining[i] -> upper bound of the score contributed by terms order[i:].
+	remaining := buf.remaining[:len(order)+1]
+	remaining[len(order)] = 0
+	for i := len(order) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + bounds[order[i]]
+	}
+
+	// Since all weights are non-negative, partial scores are lower bounds of
+	// the final scores, so the numNeeded-th best partial score is a lower bound
+	// of the score that a new document needs to make it into the results.
+	best := &acc.best
+	if canPrune {
+		best.init(len(acc.dots))
+		defer best.reset()
+	}
+
+	acceptNewDocs := true
+	for i, termIdx := range order {
+		if canPrune && acceptNewDocs && i > 0 {
+			bound := remaining[i] * (1 + boundSlack)
+			if bound < minScore || (len(best.candidates) >= numNeeded && bound < best.candidates[0].score) {
+				acceptNewDocs = false
+			}
+		}
+
+		term := &queryTFIDF[termIdx]
+		postings := me.index[term.Id]
+		if lo > 0 {
+			postings = postings[sort.Search(len(postings), func(i int) bool { return postings[i].docIdx >= lo }):]
+		}
+		for _, p := range postings {
+			if p.docIdx >= hi {
+				break
+			}
+			if !acceptNewDocs && !acc.visited[p.docIdx] {
+				continue
+			}
+			if !canPrune {
+				acc.add(p.docIdx, term.Value*p.weight)
+				continue
+			}
+
+			isNew, numTouched := !acc.visited[p.docIdx], len(acc.touched)
+			acc.add(p.docIdx, term.Value*p.weight)
+			if isNew && len(acc.touched) == numTouched {
+				best.reject(p.docIdx)
+			} else {
+				score := me.score(acc.dots[p.docIdx], normQueryTFIDF, me.docNorms[p.docIdx])
+				best.update(candidate{docIdx: p.docIdx, score: score}, numNeeded)
+			}
+		}
+	}
+}
+
+// The best (up to) k partial scores of a query, which are kept up to date as
+// the scores of documents increase.
+type partialScores struct {
+	// A min-heap, with the worst-ranked candidate at the top.
+	candidates []candidate
+
+	// slots[docIdx] -> 1 + the index of the document within candidates, 0 if
+	// the document is not in the heap, or -1 if the query's filter rejected it.
+	slots []int32
+
+	// The documents that have a non-zero slot.
+	marked []int
+}
+
+func (h *partialScores) Len() int           { return len(h.candidates) }
+func (h *partialScores) Less(i, j int) bool { return h.candidates[i].isWorseThan(h.candidates[j]) }
+func (h *partialScores) Swap(i, j int) {
+	h.candidates[i], h.candidates[j] = h.candidates[j], h.candidates[i]
+	h.slots[h.candidates[i].docIdx] = int32(i + 1)
+	h.slots[h.candidates[j].docIdx] = int32(j + 1)
+}
+func (h *partialScores) Push(x interface{}) {
+	c := x.(candidate)
+	h.slots[c.docIdx] = int32(len(h.candidates) + 1)
+	h.candidates = append(h.candidates, c)
+}
+func (h *partialScores) Pop() interface{} {
+	c := h.candidates[len(h.candidates)-1]
+	h.candidates = h.candidates[:len(h.candidates)-1]
+	return c
+}
+
+// Makes room for the slots of numDocs documents.
+func (h *partialScores) init(numDocs int) {
+	if n := numDocs - len(h.slots); n > 0 {
+		h.slots = append(h.slots, make([]int32, n)...)
+	}
+}
+
+// Sets the partial score of a document, which must not be lower than the
+// document's previous partial score.  Rejected documents are ignored.
+func (h *partialScores) update(c candidate, k int) {
+	slot := h.slots[c.docIdx]
+	switch {
+	case slot < 0:
+		return
+	case slot > 0:
+		h.candidates[slot-1].score = c.score
+		heap.Fix(h, int(slot-1))
+	case len(h.candidates) < k:
+		h.marked = append(h.marked, c.docIdx)
+		heap.Push(h, c)
+	case h.candidates[0].isWorseThan(c):
+		h.slots[h.candidates[0].docIdx] = 0
+		h.marked = append(h.marked, c.docIdx)
+		h.candidates[0] = c
+		h.slots[c.docIdx] = 1
+		heap.Fix(h, 0)
+	}
+}
+
+// Excludes a document from the partial scores.
+func (h *partialScores) reject(docIdx int) {
+	h.slots[docIdx] = -1
+	h.marked = append(h.marked, docIdx)
+}
+
+// Clears the partial scores so that they can be reused.
+func (h *partialScores) reset() {
+	for _, docIdx := range h.marked {
+		h.slots[docIdx] = 0
+	}
+	h.candidates = h.candidates[:0]
+	h.marked = h.marked[:0]
+}
+
+// Calculates, for each term, the largest contribution that the term's weight
+// within any single document can make to a score (before multiplying by the
+// query term weight).  Also returns false if any weight is negative, in which
+// case bounds cannot be used for pruning.
+func calcMaxWeights(index invertedIndex, docNorms []float64, cosine bool) (map[int]float64, bool) {
+	maxWeights := make(map[int]float64, len(index))
+	nonNegative := true
+
+	for termId, postings := range index {
+		maxWeight := 0.0
+		for _, p := range postings {
+			weight := p.weight
+			if cosine {
+				weight /= docNorms[p.docIdx]
+			}
+			maxWeight = math.Max(maxWeight, weight)
+			nonNegative = nonNegative && p.weight >= 0
+		}
+		maxWeights[termId] = maxWeight
+	}
+
+	return maxWeights, nonNegative
+}
+
+// Applies opts to a full ranking of the documents.
+func limitResults(rankedDocs []ScoredItem, opts QueryOptions) []ScoredItem {
+	numQualifying := sort.Search(len(rankedDocs), func(i int) bool {
+		return rankedDocs[i].Score < opts.MinScore
+	})
+	rankedDocs = paginate(rankedDocs[:numQualifying], opts.Offset)
+	if opts.K > 0 && len(rankedDocs) > opts.K {
+		rankedDocs = rankedDocs[:opts.K]
+	}
+	return rankedDocs
+}
+
+// Returns the items that follow the first offset items.
+func paginate(items []ScoredItem, offset int) []ScoredItem {
+	if offset >= len(items) {
+		return []ScoredItem{}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return items[offset:]
+}
+
+// A scored document, identified by its index within TFIDF.docs.
+type candidate struct {
+	docIdx int
+	score  float64
+}
+
+// Returns true if c ranks below other: it has a lower score, or the same score
+// but was added to the corpus later.
+func (c candidate) isWorseThan(other candidate) bool {
+	if c.score != other.score {
+		return c.score < other.score
+	}
+	return c.docIdx > other.docIdx
+}
+
+// A min-heap of candidates, with the worst-ranked candidate at the top.
+type candidateHeap []candidate
+
+func (h candidateHeap) Len() int            { return len(h) }
+func (h candidateHeap) Less(i, j int) bool  { return h[i].isWorseThan(h[j]) }
+func (h candidateHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *candidateHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *candidateHeap) Pop() interface{} {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// Adds c to this heap if the heap holds fewer than k candidates, or if c ranks
+// above the worst candidate in the heap (which is then evicted).
+func (h *candidateHeap) offer(c candidate, k int) {
+	if len(*h) < k {
+		heap.Push(h, c)
+	} else if (*h)[0].isWorseThan(c) {
+		(*h)[0] = c
+		heap.Fix(h, 0)
+	}
+}
@@ -0,0 +1,158 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestTFIDF_Search(t *testing.T) {
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	model.AddDoc(10, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}})
+	model.AddDoc(20, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 3, Value: 1}})
+	model.AddDoc(30, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}})
+	model.AddDoc(40, vectors.SparseVector{{Id: 4, Value: 1}})
+	model.Train()
+
+	query := vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}}
+	all := model.SimilarDocsForText(query)
+	assert.Equal(t, []int{10, 30, 20, 40}, scoredItemIds(all))
+
+	// Search() leaves out document 40, which scores 0.
+	all = all[:3]
+
+	assert.Equal(t, all, model.Search(query, QueryOptions{}))
+	assert.Equal(t, all[:2], model.Search(query, QueryOptions{K: 2}))
+	assert.Equal(t, all[1:3], model.Search(query, QueryOptions{K: 2, Offset: 1}))
+	assert.Equal(t, all[2:], model.Search(query, QueryOptions{K: 10, Offset: 2}))
+	assert.Equal(t, []ScoredItem{}, model.Search(query, QueryOptions{K: 10, Offset: 3}))
+
+	// A negative offset is treated as 0.
+	assert.Equal(t, all[:1], model.Search(query, QueryOptions{K: 1, Offset: -1}))
+	assert.Equal(t, all, model.Search(query, QueryOptions{Offset: -1}))
+	batch, err := model.SearchBatch([]vectors.SparseVector{query}, QueryOptions{K: 1, Offset: -1})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]ScoredItem{all[:1]}, batch)
+	assert.Equal(t, all[:2], model.Search(query, QueryOptions{K: 10, MinScore: all[1].Score}))
+	assert.Equal(t, all[:2], model.Search(query, QueryOptions{MinScore: all[1].Score}))
+	assert.Equal(t, []ScoredItem{}, model.Search(vectors.SparseVector{{Id: 99, Value: 1}}, QueryOptions{K: 10}))
+}
+
+// Verifies that Search() returns exactly the corresponding slice of the full
+// ranking, whether or not pruning kicks in.
+func TestTFIDF_Search_matchesFullRanking(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	corpus := makeRandomCorpus(rng, 1000, 3000, 40)
+
+	for _, scheme := range []SMART{{}, {TF: 'l', IDF: 't', Norm: 'c'}, {TF: 'a', IDF: 'f', Norm: 'n'}} {
+		model := NewTFIDF()
+		model.StopWordThreshold = 0.5
+		model.Scheme = scheme
+		for docId, doc := range corpus {
+			model.AddDoc(docId, doc)
+		}
+		model.Train()
+
+		for i := 0; i < 100; i++ {
+			query := makeRandomCorpus(rng, 1, 3000, 8)[0]
+			all := matchedItems(model.SimilarDocsForText(query))
+
+			opts := QueryOptions{K: 1 + rng.Intn(20), Offset: rng.Intn(3)}
+			if i%4 == 0 && len(all) > 0 {
+				opts.MinScore = all[rng.Intn(len(all))].Score
+			}
+
+			expected := []ScoredItem{}
+			for j, item := range all {
+				if j >= opts.Offset && j < opts.Offset+opts.K && item.Score >= opts.MinScore {
+					expected = append(expected, item)
+				}
+			}
+
+			assert.Equal(t, expected, model.Search(query, opts), "scheme=%v opts=%+v", scheme, opts)
+		}
+	}
+}
+
+func TestCandidateHeap(t *testing.T) {
+	h := candidateHeap{}
+	for i, score := range []float64{0.5, 0.1, 0.9, 0.5, 0.7} {
+		h.offer(candidate{docIdx: i, score: score}, 3)
+	}
+
+	// The worst of the top 3 (0.9, 0.7, 0.5@0) is at the top of the heap.
+	assert.Equal(t, 3, len(h))
+	assert.Equal(t, candidate{docIdx: 0, score: 0.5}, h[0])
+}
+
+// Verifies that partialScores always holds the k best of the scores so far, as
+// the scores of documents increase.
+func TestPartialScores(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	const numDocs, k = 50, 5
+
+	h := &partialScores{}
+	h.init(numDocs)
+	h.reject(3)
+	scores := make([]float64, numDocs)
+	for i := 0; i < 1000; i++ {
+		docIdx := rng.Intn(numDocs)
+		scores[docIdx] += rng.Float64()
+		h.update(candidate{docIdx: docIdx, score: scores[docIdx]}, k)
+
+		expected := candidateHeap{}
+		for docIdx, score := range scores {
+			if docIdx != 3 && score > 0 {
+				expected.offer(candidate{docIdx: docIdx, score: score}, k)
+			}
+		}
+		assert.ElementsMatch(t, expected, h.candidates)
+		assert.Equal(t, expected[0], h.candidates[0])
+		for j, c := range h.candidates {
+			assert.Equal(t, int32(j+1), h.slots[c.docIdx])
+		}
+	}
+
+	h.reset()
+	assert.Equal(t, 0, len(h.candidates))
+	assert.Equal(t, make([]int32, numDocs), h.slots)
+}
+
+func TestPaginate(t *testing.T) {
+	items := []ScoredItem{{Id: 1, Score: 3}, {Id: 2, Score: 2}}
+	assert.Equal(t, items, paginate(items, 0))
+	assert.Equal(t, items[1:], paginate(items, 1))
+	assert.Equal(t, []ScoredItem{}, paginate(items, 2))
+	assert.Equal(t, items, paginate(items, -1))
+}
+
+func BenchmarkTFIDF_Search_top10(b *testing.B) {
+	model, queries := makeBenchmarkModel(20000)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		model.search(queries[n%len(queries)], QueryOptions{K: 10})
+	}
+}
+
+func scoredItemIds(items []ScoredItem) []int {
+	ids := make([]int, len(items))
+	for i, item := range items {
+		ids[i] = item.Id
+	}
+	return ids
+}
+
+// Returns the items of a ranking that have a score > 0, i.e. the ones that
+// Search() returns.
+func matchedItems(rankedDocs []ScoredItem) []ScoredItem {
+	matched := []ScoredItem{}
+	for _, item := range rankedDocs {
+		if item.Score > 0 {
+			matched = append(matched, item)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,210 @@
+package tfidf
+
+import (
+	"fmt"
+	"github.com/cet001/mathext/vectors"
+	"math"
+)
+
+// Weights smaller than this (in absolute value) are treated as zero.
+const weightEpsilon = 1e-12
+
+// A term weighting scheme in SMART notation (e.g. "ltc"), consisting of a term
+// frequency, a document frequency and a normalization component.  The formulas
+// (including the use of base-2 logarithms) are the same as those of gensim's
+// TfidfModel, so that models can be compared.
+//
+// Term frequency (TF):
+//
+//	n - natural:     tf
+//	l - logarithm:   1 + log2(tf)
+//	d - double log:  1 + log2(1 + log2(tf))
+//	a - augmented:   0.5 + 0.5 * tf / max(tf)
+//	b - boolean:     1 if tf > 0, else 0
+//	L - log average: (1 + log2(tf)) / (1 + log2(avg(tf)))
+//
+// Inverse document frequency (IDF):
+//
+//	n - none:                 1
+//	f - idf:                  log2(N / df)
+//	t - zero-corrected idf:   log2((N + 1) / df), a smoothed idf
+//	p - probabilistic idf:    max(0, log2((N - df) / df))
+//
+// Normalization (Norm):
+//
+//	n - none
+//	c - cosine:               divides by the L2 norm
+//	u - pivoted unique:       divides by (1 - slope) * pivot + slope * (number of unique terms)
+//
+// The natural, logarithm, double log and log average TF schemes expect raw term
+// counts (see gosim.Dictionary.VectorizeCounts()).
+//
+// The zero value SMART{} selects this package's original weighting scheme:
+// relative term frequency * (1 + ln(N / df)), with cosine similarity at query
+// time.
+type SMART struct {
+	TF   byte
+	IDF  byte
+	Norm byte
+}
+
+// Parses a 3-letter SMART notation string such as "ltc".
+func ParseSMART(s string) (SMART, error) {
+	if len(s) != 3 {
+		return SMART{}, fmt.Errorf("invalid SMART scheme '%v': expected 3 letters", s)
+	}
+
+	scheme := SMART{TF: s[0], IDF: s[1], Norm: s[2]}
+	if err := scheme.validate(); err != nil {
+		return SMART{}, err
+	}
+	return scheme, nil
+}
+
+// Returns the SMART notation for this scheme (e.g. "ltc"), or "" for the zero
+// value.
+func (me SMART) String() string {
+	if me.isLegacy() {
+		return ""
+	}
+	return string([]byte{me.TF, me.IDF, me.Norm})
+}
+
+func (me SMART) validate() error {
+	if me.isLegacy() {
+		return nil
+	}
+
+	if !isOneOf(me.TF, "nldabL") {
+		return fmt.Errorf("invalid SMART scheme '%v': unknown TF weighting '%c'", me, me.TF)
+	}
+	if !isOneOf(me.IDF, "nftp") {
+		return fmt.Errorf("invalid SMART scheme '%v': unknown IDF weighting '%c'", me, me.IDF)
+	}
+	if !isOneOf(me.Norm, "ncu") {
+		return fmt.Errorf("invalid SMART scheme '%v': unknown normalization '%c'", me, me.Norm)
+	}
+	return nil
+}
+
+func (me SMART) isLegacy() bool {
+	return me == SMART{}
+}
+
+// Calculates the IDF weight of a term that appears in df of the totalDocs
+// documents in the corpus.
+func (me SMART) idf(df, totalDocs int) float64 {
+	N, DF := float64(totalDocs), float64(df)
+
+	switch me.IDF {
+	case 'f':
+		return math.Log2(N / DF)
+	case 't':
+		return math.Log2((N + 1.0) / DF)
+	case 'p':
+		return math.Max(0, math.Log2((N-DF)/DF))
+	case 'n':
+		return 1.0
+	default:
+		return 1.0 + math.Log(N/DF) // legacy scheme
+	}
+}
+
+// Applies the TF component of this scheme to each term in tf.
+func (me SMART) weighTF(tf vectors.SparseVector) []float64 {
+	weights := make([]float64, len(tf))
+
+	var maxTF, sumTF float64
+	for i := range tf {
+		maxTF = math.Max(maxTF, tf[i].Value)
+		sumTF += tf[i].Value
+	}
+	avgTF := sumTF / float64(len(tf))
+
+	for i := range tf {
+		x := tf[i].Value
+		switch me.TF {
+		case 'l':
+			x = 1.0 + math.Log2(x)
+		case 'd':
+			x = 1.0 + math.Log2(1.0+math.Log2(x))
+		case 'a':
+			x = 0.5 + (0.5 * x / maxTF)
+		case 'b':
+			if x > 0 {
+				x = 1.0
+			} else {
+				x = 0.0
+			}
+		case 'L':
+			x = (1.0 + math.Log2(x)) / (1.0 + math.Log2(avgTF))
+		}
+		weights[i] = x
+	}
+
+	return weights
+}
+
+// Calculates the weighted vector of the term frequency vector tf, given the IDF
+// of each term in the corpus.  pivot and slope are used for pivoted unique
+// normalization.
+//
+// Terms without an IDF weight (e.g. terms that are not in the corpus) are
+// dropped from the result, except under the legacy scheme.
+func (me SMART) weigh(tf vectors.SparseVector, idfs sparseHashVector, pivot, slope float64) vectors.SparseVector {
+	if me.isLegacy() {
+		return calcTFIDF(tf, idfs)
+	}
+
+	tfWeights := me.weighTF(tf)
+	weighted := make(vectors.SparseVector, 0, len(tf))
+	for i := range tf {
+		idf := idfs[tf[i].Id]
+		if math.Abs(idf) > weightEpsilon {
+			weighted = append(weighted, vectors.Element{Id: tf[i].Id, Value: tfWeights[i] * idf})
+		}
+	}
+
+	var norm float64
+	switch me.Norm {
+	case 'c':
+		norm = vectors.Norm(weighted)
+	case 'u':
+		norm = (1.0-slope)*pivot + slope*float64(len(weighted))
+	default:
+		return weighted
+	}
+
+	if norm > 0 {
+		for i := range weighted {
+			weighted[i].Value /= norm
+		}
+	}
+	return weighted
+}
+
+// Calculates the similarity score of 2 weighted vectors, given their dot product
+// and norms: cosine similarity under the legacy scheme, or the dot product under
+// a SMART scheme.  A zero vector scores 0 against any vector.
+func (me SMART) score(dot, norm1, norm2 float64) float64 {
+	if me.isLegacy() {
+		if norm1 == 0 || norm2 == 0 {
+			return 0
+		}
+		return math.Min(1.0, dot/(norm1*norm2))
+	}
+
+	if me.Norm == 'c' {
+		return math.Min(1.0, dot)
+	}
+	return dot
+}
+
+func isOneOf(c byte, chars string) bool {
+	for i := 0; i < len(chars); i++ {
+		if chars[i] == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+// Raw term count vectors
+var smartTestCorpus = []vectors.SparseVector{
+	{{Id: 1, Value: 2}, {Id: 2, Value: 1}, {Id: 3, Value: 1}},
+	{{Id: 1, Value: 1}, {Id: 3, Value: 3}, {Id: 4, Value: 1}},
+	{{Id: 2, Value: 1}, {Id: 4, Value: 2}, {Id: 5, Value: 1}},
+	{{Id: 1, Value: 1}, {Id: 5, Value: 4}},
+	{{Id: 6, Value: 2}, {Id: 7, Value: 1}},
+}
+
+// Expected document weights for smartTestCorpus, computed with the weighting
+// formulas of gensim's TfidfModel(corpus, smartirs=scheme) (slope=0.25, with
+// the pivot defaulting to the average number of unique terms per document).
+var smartTestExpectedWeights = map[string][]vectors.SparseVector{
+	"nfc": {
+		{{Id: 1, Value: 0.6191315583062657}, {Id: 2, Value: 0.5552819614886004}, {Id: 3, Value: 0.5552819614886004}},
+		{{Id: 1, Value: 0.17361739380903232}, {Id: 3, Value: 0.9342758160790973}, {Id: 4, Value: 0.3114252720263658}},
+		{{Id: 2, Value: 0.408248290463863}, {Id: 4, Value: 0.816496580927726}, {Id: 5, Value: 0.408248290463863}},
+		{{Id: 1, Value: 0.13803898917234142}, {Id: 5, Value: 0.9904267956130216}},
+		{{Id: 6, Value: 0.894427190999916}, {Id: 7, Value: 0.447213595499958}},
+	},
+	"ltc": {
+		{{Id: 1, Value: 0.6657717217086951}, {Id: 2, Value: 0.5276116064744215}, {Id: 3, Value: 0.5276116064744215}},
+		{{Id: 1, Value: 0.22195889139757585}, {Id: 3, Value: 0.9093808109643875}, {Id: 4, Value: 0.3517965195667973}},
+		{{Id: 2, Value: 0.408248290463863}, {Id: 4, Value: 0.816496580927726}, {Id: 5, Value: 0.408248290463863}},
+		{{Id: 1, Value: 0.20580769863329612}, {Id: 5, Value: 0.9785924540804852}},
+		{{Id: 6, Value: 0.894427190999916}, {Id: 7, Value: 0.447213595499958}},
+	},
+	"atn": {
+		{{Id: 1, Value: 1.0}, {Id: 2, Value: 1.188721875540867}, {Id: 3, Value: 1.188721875540867}},
+		{{Id: 1, Value: 0.6666666666666666}, {Id: 3, Value: 1.584962500721156}, {Id: 4, Value: 1.0566416671474372}},
+		{{Id: 2, Value: 1.188721875540867}, {Id: 4, Value: 1.584962500721156}, {Id: 5, Value: 1.188721875540867}},
+		{{Id: 1, Value: 0.625}, {Id: 5, Value: 1.584962500721156}},
+		{{Id: 6, Value: 2.584962500721156}, {Id: 7, Value: 1.938721875540867}},
+	},
+	"bpn": {
+		{{Id: 2, Value: 0.5849625007211562}, {Id: 3, Value: 0.5849625007211562}},
+		{{Id: 3, Value: 0.5849625007211562}, {Id: 4, Value: 0.5849625007211562}},
+		{{Id: 2, Value: 0.5849625007211562}, {Id: 4, Value: 0.5849625007211562}, {Id: 5, Value: 0.5849625007211562}},
+		{{Id: 5, Value: 0.5849625007211562}},
+		{{Id: 6, Value: 2.0}, {Id: 7, Value: 2.0}},
+	},
+	"Ltu": {
+		{{Id: 1, Value: 0.5234778167492027}, {Id: 2, Value: 0.41484635475343373}, {Id: 3, Value: 0.41484635475343373}},
+		{{Id: 1, Value: 0.21322838610868333}, {Id: 3, Value: 0.8736113316263381}, {Id: 4, Value: 0.33795899607155494}},
+		{{Id: 2, Value: 0.41484635475343373}, {Id: 4, Value: 0.8296927095068675}, {Id: 5, Value: 0.41484635475343373}},
+		{{Id: 1, Value: 0.17578635023403794}, {Id: 5, Value: 0.8358443197787572}},
+		{{Id: 6, Value: 1.3313712274052714}, {Id: 7, Value: 0.6656856137026357}},
+	},
+	"dtc": {
+		{{Id: 1, Value: 0.6657717217086951}, {Id: 2, Value: 0.5276116064744215}, {Id: 3, Value: 0.5276116064744215}},
+		{{Id: 1, Value: 0.23820287203829782}, {Id: 3, Value: 0.8948301302643721}, {Id: 4, Value: 0.37754261974478204}},
+		{{Id: 2, Value: 0.408248290463863}, {Id: 4, Value: 0.816496580927726}, {Id: 5, Value: 0.408248290463863}},
+		{{Id: 1, Value: 0.23711618872479412}, {Id: 5, Value: 0.9714812983504253}},
+		{{Id: 6, Value: 0.894427190999916}, {Id: 7, Value: 0.447213595499958}},
+	},
+}
+
+func TestParseSMART(t *testing.T) {
+	scheme, err := ParseSMART("ltc")
+	assert.Nil(t, err)
+	assert.Equal(t, SMART{TF: 'l', IDF: 't', Norm: 'c'}, scheme)
+	assert.Equal(t, "ltc", scheme.String())
+	assert.Equal(t, "", SMART{}.String())
+
+	for _, s := range []string{"", "lt", "ltcx", "xtc", "lxc", "ltx"} {
+		_, err := ParseSMART(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestSMART_weigh(t *testing.T) {
+	for notation, expectedWeights := range smartTestExpectedWeights {
+		model := newSMARTTestModel(t, notation)
+
+		for i, doc := range model.docs {
+			if assert.Equal(t, len(expectedWeights[i]), len(doc.TFIDF), notation) {
+				for j, expected := range expectedWeights[i] {
+					assert.Equal(t, expected.Id, doc.TFIDF[j].Id, notation)
+					assert.InDelta(t, expected.Value, doc.TFIDF[j].Value, 1e-12, notation)
+				}
+			}
+		}
+	}
+}
+
+func TestSMART_weigh_unknownTerms(t *testing.T) {
+	model := newSMARTTestModel(t, "ntn")
+
+	// Term 99 is not in the corpus, so it is dropped
+	weighted := model.weigh(vectors.SparseVector{{Id: 6, Value: 2}, {Id: 99, Value: 5}})
+	assert.Equal(t, 1, len(weighted))
+	assert.Equal(t, 6, weighted[0].Id)
+}
+
+func TestTFIDF_CalcSimilarity_SMART(t *testing.T) {
+	model := newSMARTTestModel(t, "ltc")
+
+	// Cosine-normalized vectors: the score is the dot product, i.e. the cosine.
+	assert.InDelta(t, 1.0, model.CalcSimilarity(smartTestCorpus[0], smartTestCorpus[0]), 1e-12)
+	assert.Equal(t, 0.0, model.CalcSimilarity(smartTestCorpus[0], smartTestCorpus[4]))
+
+	expected := vectors.Dot(model.docs[0].TFIDF, model.docs[1].TFIDF)
+	assert.InDelta(t, expected, model.CalcSimilarity(smartTestCorpus[0], smartTestCorpus[1]), 1e-12)
+
+	similarDocs := model.SimilarDocsForText(smartTestCorpus[3])
+	assert.Equal(t, 3, similarDocs[0].Id)
+}
+
+func TestTFIDF_Train_invalidScheme(t *testing.T) {
+	model := NewTFIDF()
+	model.Scheme = SMART{TF: 'x', IDF: 'f', Norm: 'c'}
+	model.AddDoc(1, smartTestCorpus[0])
+	assert.Panics(t, func() { model.Train() })
+}
+
+func TestSaveAndLoadTFIDF_SMART(t *testing.T) {
+	model := NewTFIDF()
+	model.Scheme = SMART{TF: 'L', IDF: 't', Norm: 'u'}
+	model.Pivot = 12.5
+	model.Slope = 0.3
+
+	dataFile := "/tmp/gosim_TestSaveAndLoadTFIDF_SMART.dat"
+	defer os.Remove(dataFile)
+
+	assert.Nil(t, model.Save(dataFile))
+	reloadedModel, err := LoadTFIDF(dataFile)
+	if assert.Nil(t, err) {
+		assert.Equal(t, model.Scheme, reloadedModel.Scheme)
+		assert.Equal(t, 12.5, reloadedModel.Pivot)
+		assert.Equal(t, 0.3, reloadedModel.Slope)
+	}
+}
+
+func newSMARTTestModel(t *testing.T, notation string) *TFIDF {
+	scheme, err := ParseSMART(notation)
+	assert.Nil(t, err)
+
+	return newTestModel(scheme, smartTestCorpus)
+}
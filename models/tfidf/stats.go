@@ -0,0 +1,141 @@
+package tfidf
+
+import (
+	"encoding/json"
+	"github.com/cet001/mathext/vectors"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+// How long each phase of Train() took.  Durations are exported to JSON in
+// nanoseconds.
+type PhaseDurations struct {
+	DocFrequencies time.Duration
+	StopWords      time.Duration
+	Pruning        time.Duration
+	Filtering      time.Duration
+	IDF            time.Duration
+	Weighting      time.Duration
+	Indexing       time.Duration
+	Total          time.Duration
+}
+
+// Summarizes a distribution of values.  Percentiles use the nearest-rank
+// method.
+type Distribution struct {
+	Min    float64
+	Max    float64
+	Mean   float64
+	Median float64
+	P90    float64
+	P99    float64
+}
+
+// The number of terms whose document frequency falls within [MinDocFreq,
+// MaxDocFreq].
+type HistogramBucket struct {
+	MinDocFreq int
+	MaxDocFreq int
+	TermCount  int
+}
+
+// Approximate memory used by a trained model, in bytes.
+type MemoryEstimate struct {
+	// The documents, including their term frequency and weighted vectors.
+	Documents int64
+
+	// The inverted index and per-document norms.
+	Index int64
+
+	// The IDF values and document frequencies of the vocabulary terms.
+	Vocabulary int64
+
+	Total int64
+}
+
+// Returns these stats as indented JSON.
+func (me Stats) JSON() ([]byte, error) {
+	return json.MarshalIndent(me, "", "  ")
+}
+
+// Calculates the distribution of the specified values.
+func calcDistribution(values []int) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	sum := 0
+	for _, v := range sorted {
+		sum += v
+	}
+	percentile := func(p int) float64 {
+		rank := (p*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+		return float64(sorted[rank-1])
+	}
+
+	return Distribution{
+		Min:    float64(sorted[0]),
+		Max:    float64(sorted[len(sorted)-1]),
+		Mean:   float64(sum) / float64(len(sorted)),
+		Median: percentile(50),
+		P90:    percentile(90),
+		P99:    percentile(99),
+	}
+}
+
+// Builds a histogram of the specified document frequencies, with buckets whose
+// bounds are powers of 2: [1, 1], [2, 3], [4, 7], etc.  Empty buckets beyond
+// the highest document frequency are omitted, and so are terms with a
+// document frequency of 0 (whose documents have all been removed).
+func docFreqHistogram(docFreqs map[int]int) []HistogramBucket {
+	histogram := []HistogramBucket{}
+	for _, df := range docFreqs {
+		if df < 1 {
+			continue
+		}
+		bucket := 0
+		for (2 << uint(bucket)) <= df {
+			bucket++
+		}
+		for len(histogram) <= bucket {
+			lo := 1 << uint(len(histogram))
+			histogram = append(histogram, HistogramBucket{MinDocFreq: lo, MaxDocFreq: 2*lo - 1})
+		}
+		histogram[bucket].TermCount++
+	}
+	return histogram
+}
+
+// Estimates the memory used by this model's documents, index and vocabulary.
+// Map entries are assumed to cost about twice the size of their key and value.
+func (me *TFIDF) estimateMemory() MemoryEstimate {
+	const (
+		elementSize = int64(unsafe.Sizeof(vectors.Element{}))
+		postingSize = int64(unsafe.Sizeof(posting{}))
+		sliceSize   = int64(unsafe.Sizeof([]posting{}))
+		wordSize    = int64(unsafe.Sizeof(int(0)))
+	)
+
+	var estimate MemoryEstimate
+	estimate.Documents = int64(cap(me.docs)) * int64(unsafe.Sizeof(Document{}))
+	for i := range me.docs {
+		estimate.Documents += int64(cap(me.docs[i].TF)+cap(me.docs[i].TFIDF)) * elementSize
+	}
+
+	estimate.Index = int64(len(me.docNorms)) * wordSize
+	for _, postings := range me.index {
+		estimate.Index += 2*(wordSize+sliceSize) + int64(cap(postings))*postingSize
+	}
+
+	estimate.Vocabulary = int64(len(me.idf)+len(me.df)+len(me.maxWeights)) * 2 * 2 * wordSize
+
+	estimate.Total = estimate.Documents + estimate.Index + estimate.Vocabulary
+	return estimate
+}
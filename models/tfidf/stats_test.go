@@ -0,0 +1,124 @@
+package tfidf
+
+import (
+	"encoding/json"
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTFIDF_Train_stats(t *testing.T) {
+	docs := []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 3, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 4, Value: 1}},
+		{{Id: 5, Value: 1}}, // empty once stop word 5 is removed
+	}
+
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	model.StopWordIds = []int{5}
+	for docId, doc := range docs {
+		model.AddDoc(docId, doc)
+	}
+
+	stats := model.Train()
+	assert.Equal(t, stats, model.Stats())
+	assert.Equal(t, 3, stats.RareTermCount) // terms 3, 4 and 5
+	assert.Equal(t, 1, stats.EmptyDocCount)
+	assert.Equal(t, Distribution{Min: 0, Max: 3, Mean: 1.75, Median: 2, P90: 3, P99: 3}, stats.DocLengths)
+	assert.Equal(t, []HistogramBucket{
+		{MinDocFreq: 1, MaxDocFreq: 1, TermCount: 2},
+		{MinDocFreq: 2, MaxDocFreq: 3, TermCount: 2},
+	}, stats.DocFreqHistogram)
+
+	memory := stats.Memory
+	assert.True(t, memory.Documents > 0)
+	assert.True(t, memory.Index > 0)
+	assert.True(t, memory.Vocabulary > 0)
+	assert.Equal(t, memory.Documents+memory.Index+memory.Vocabulary, memory.Total)
+
+	durations := stats.Durations
+	assert.True(t, durations.Total > 0)
+	assert.True(t, durations.Total >= durations.DocFrequencies+durations.Weighting+durations.Indexing)
+}
+
+// Verifies that the corpus stats are updated when the corpus is reindexed after
+// changes, while the training stats are kept.
+func TestTFIDF_Stats_afterUpdates(t *testing.T) {
+	model := newTestModel(SMART{}, []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 3, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}},
+	})
+	model.MaxStaleUpdates = 1
+	model.MaxStaleFraction = 0
+	trained := model.Stats()
+
+	assert.Nil(t, model.RemoveDoc(0))
+	assert.Equal(t, trained, model.Stats())
+
+	model.AddDoc(10, vectors.SparseVector{{Id: 2, Value: 1}, {Id: 4, Value: 1}})
+	stats := model.Stats()
+	assert.Equal(t, 2, stats.DocumentCount)
+	assert.Equal(t, 2, stats.TermCount) // terms 1 and 2, but not the removed 3 or the unknown 4
+	assert.Equal(t, 0, stats.EmptyDocCount)
+	assert.Equal(t, Distribution{Min: 1, Max: 2, Mean: 1.5, Median: 1, P90: 2, P99: 2}, stats.DocLengths)
+	assert.Equal(t, []HistogramBucket{
+		{MinDocFreq: 1, MaxDocFreq: 1, TermCount: 1},
+		{MinDocFreq: 2, MaxDocFreq: 3, TermCount: 1},
+	}, stats.DocFreqHistogram)
+	assert.Equal(t, trained.RareTermCount, stats.RareTermCount)
+	assert.Equal(t, trained.Durations, stats.Durations)
+}
+
+func TestStats_JSON(t *testing.T) {
+	stats := Stats{
+		DocumentCount: 3,
+		TermCount:     10,
+		StopWords:     vectors.SparseVector{{Id: 7, Value: 3}},
+		StopWordRules: map[int]RemovalRule{7: RemovedByStopWordThreshold},
+		PrunedTerms:   []RemovedTerm{{Id: 8, DocFreq: 1, Rule: RemovedByMinDocFreq}},
+		RareTermCount: 4,
+		DocLengths:    Distribution{Min: 1, Max: 5, Mean: 3, Median: 3, P90: 5, P99: 5},
+	}
+
+	data, err := stats.JSON()
+	assert.Nil(t, err)
+
+	var fields map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, map[string]interface{}{"7": "StopWordThreshold"}, fields["StopWordRules"])
+	assert.Equal(t, "MinDocFreq", fields["PrunedTerms"].([]interface{})[0].(map[string]interface{})["Rule"])
+
+	var decoded Stats
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, stats, decoded)
+
+	var rule RemovalRule
+	assert.NotNil(t, json.Unmarshal([]byte(`"NoSuchRule"`), &rule))
+}
+
+func TestCalcDistribution(t *testing.T) {
+	assert.Equal(t, Distribution{}, calcDistribution([]int{}))
+	assert.Equal(t, Distribution{Min: 4, Max: 4, Mean: 4, Median: 4, P90: 4, P99: 4}, calcDistribution([]int{4}))
+
+	values := make([]int, 100)
+	for i := range values {
+		values[i] = 100 - i // 100..1
+	}
+	assert.Equal(t, Distribution{Min: 1, Max: 100, Mean: 50.5, Median: 50, P90: 90, P99: 99}, calcDistribution(values))
+	assert.Equal(t, 100, values[0]) // the input is not modified
+}
+
+func TestDocFreqHistogram(t *testing.T) {
+	assert.Equal(t, []HistogramBucket{}, docFreqHistogram(map[int]int{}))
+	assert.Equal(t,
+		[]HistogramBucket{
+			{MinDocFreq: 1, MaxDocFreq: 1, TermCount: 1},
+			{MinDocFreq: 2, MaxDocFreq: 3, TermCount: 2},
+			{MinDocFreq: 4, MaxDocFreq: 7, TermCount: 0},
+			{MinDocFreq: 8, MaxDocFreq: 15, TermCount: 1},
+		},
+		docFreqHistogram(map[int]int{1: 1, 2: 2, 3: 3, 4: 8}),
+	)
+}
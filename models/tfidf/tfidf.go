@@ -8,27 +8,50 @@ package tfidf
 //
 
 import (
-	"encoding/gob"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/cet001/mathext/vectors"
-	"log"
-	"math"
-	"os"
 	"sort"
+	"sync"
 	"time"
 )
 
-var logger = log.New(os.Stderr, "[gosim] ", (log.Ldate | log.Ltime | log.Lshortfile))
+var (
+	// Returned by AddDoc() when the corpus already contains a document with the
+	// same Id.
+	ErrDuplicateDocId = errors.New("tfidf: duplicate document Id")
+
+	// Returned when the corpus contains no document with the specified Id.
+	ErrUnknownDocId = errors.New("tfidf: unknown document Id")
+
+	// Returned by queries when documents have been added since the last call to
+	// Train() (or Train() has never been called).
+	ErrNotTrained = errors.New("tfidf: corpus stats need to be recalculated; call Train()")
+
+	// Returned by queries when the query vector has no terms.
+	ErrEmptyQuery = errors.New("tfidf: empty query vector")
+
+	// Returned by queries when the weighted query vector is a zero vector.
+	ErrZeroNorm = errors.New("tfidf: weighted query vector has a norm of 0")
+)
 
 // Represents a vectorized document within a corpus.
 type Document struct {
 	// Unique document ID within a given corpus.
 	Id int
 
-	// Term frequency of each distinct term in this document.
+	// Term frequency of each distinct term in this document.  Training removes
+	// the terms that are not in the vocabulary, while documents added after
+	// training keep all of their terms.
 	TF vectors.SparseVector
 
 	// TF-IDF score of each distinct term x in this document.
 	TFIDF vectors.SparseVector
+
+	// Optional metadata that queries can filter on.
+	Attributes Attributes
 }
 
 // Statistics that were gathered during the training phase (see Train()).
@@ -41,12 +64,100 @@ type Stats struct {
 
 	// The stop words that were identified by this algorithm.
 	StopWords []vectors.Element
+
+	// StopWordRules[t] -> the rule (RemovedByStopWordList or
+	// RemovedByStopWordThreshold) that made term t a stop word.
+	StopWordRules map[int]RemovalRule
+
+	// The (non-stopword) terms that were pruned from the vocabulary by the
+	// MinDocFreq, MaxDocFreq and MaxVocabulary settings.
+	PrunedTerms []RemovedTerm
+
+	// The number of terms that were present in only 1 document, before stop
+	// words were removed and the vocabulary was pruned.
+	RareTermCount int
+
+	// The number of documents that have no weighted terms left after
+	// filtering, and therefore never match any query.
+	EmptyDocCount int
+
+	// The distribution of the number of distinct (vocabulary) terms per
+	// document, after filtering.
+	DocLengths Distribution
+
+	// Histogram of the document frequencies of the vocabulary terms.
+	DocFreqHistogram []HistogramBucket
+
+	// Approximate memory used by the trained model.
+	Memory MemoryEstimate
+
+	// How long each phase of the training took.
+	Durations PhaseDurations
+}
+
+// Identifies the rule that caused a term to be removed during training.
+type RemovalRule int
+
+const (
+	RemovedByMinDocFreq RemovalRule = iota + 1
+	RemovedByMaxDocFreq
+	RemovedByMaxVocabulary
+	RemovedByStopWordThreshold
+	RemovedByStopWordList
+)
+
+func (r RemovalRule) String() string {
+	switch r {
+	case RemovedByMinDocFreq:
+		return "MinDocFreq"
+	case RemovedByMaxDocFreq:
+		return "MaxDocFreq"
+	case RemovedByMaxVocabulary:
+		return "MaxVocabulary"
+	case RemovedByStopWordThreshold:
+		return "StopWordThreshold"
+	case RemovedByStopWordList:
+		return "StopWordIds"
+	default:
+		return "Unknown"
+	}
+}
+
+// Exports the rule by name (see String()).
+func (r RemovalRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *RemovalRule) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	for rule := RemovedByMinDocFreq; rule <= RemovedByStopWordList; rule++ {
+		if rule.String() == name {
+			*r = rule
+			return nil
+		}
+	}
+	return fmt.Errorf("tfidf: unknown removal rule %q", name)
+}
+
+// A term that was removed from the vocabulary during training.
+type RemovedTerm struct {
+	Id      int
+	DocFreq int
+	Rule    RemovalRule
 }
 
 // Anything that can be represented as a unique Id and associated score.
 type ScoredItem struct {
 	Id    int
 	Score float64
+
+	// The query terms that contribute the most to Score.  Only set by Search()
+	// when QueryOptions.TopTerms > 0.
+	TopTerms TermScores
 }
 
 // Sorts ScoredItem objects in descending order by score.
@@ -67,186 +178,507 @@ type TFIDF struct {
 	// range is [0..1], where 0 = 0% and 1 = 100%.
 	StopWordThreshold float64
 
+	// Term Ids that are always treated as stop words, regardless of their
+	// document frequency.  Takes precedence over ProtectedTermIds.
+	StopWordIds []int
+
+	// Term Ids that are never treated as stop words, even if they exceed
+	// StopWordThreshold or MaxDocFreq (e.g. a product name that appears in
+	// most documents).  Protected terms can still be pruned by MinDocFreq and
+	// MaxVocabulary.
+	ProtectedTermIds []int
+
+	// Terms that are present in fewer documents than this are pruned.  Values
+	// in the range (0..1) are a fraction of the documents in the corpus, while
+	// values >= 1 are absolute document counts (so 1 means a single document,
+	// not 100%).  0 disables this rule.
+	MinDocFreq float64
+
+	// Terms that are present in more documents than this are pruned.  Accepts
+	// the same values as MinDocFreq.  This complements StopWordThreshold, but
+	// terms removed by this rule are not reported as stop words.
+	MaxDocFreq float64
+
+	// If > 0, only the MaxVocabulary terms with the highest document frequency
+	// are kept (ties are broken in favor of lower term Ids).
+	MaxVocabulary int
+
+	// The number of goroutines used to train the model and to score queries.
+	// Values < 2 mean that all work is done on the calling goroutine.  Results
+	// are identical regardless of this setting.
+	Workers int
+
+	// The term weighting scheme.  The zero value selects the original weighting
+	// scheme of this package (see SMART).
+	Scheme SMART
+
+	// The pivot used by pivoted unique normalization (Scheme.Norm == 'u').  If
+	// 0, the average number of unique terms per document is used.
+	Pivot float64
+
+	// The slope used by pivoted unique normalization (Scheme.Norm == 'u').
+	Slope float64
+
+	// Once a model has been trained, documents can still be added, replaced
+	// and removed.  The document frequencies are updated immediately, but the
+	// IDF values and the weights of the other documents are only recalculated
+	// once more than MaxStaleUpdates such changes, and more than
+	// MaxStaleFraction * (the number of documents) of them, have accumulated.
+	//
+	// Each recalculation takes time proportional to the size of the corpus.
+	// With the default MaxStaleFraction of 0.1, adding N documents one at a
+	// time to a trained model costs O(N) in total, at the price of slightly
+	// stale scores in between.  Setting both fields to 0 recalculates after
+	// every change, which keeps scores exact but makes adding N documents
+	// cost O(N^2).
+	MaxStaleUpdates  int
+	MaxStaleFraction float64
+
+	// Receives the progress messages of Train().  If nil, messages are written
+	// to stderr.  Set to NopLogger to silence them.
+	Logger Logger
+
+	// If not nil, called with the number of documents processed so far by
+	// each phase of Train().  Calls are serialized, but may be made from any
+	// of the training's goroutines (see Workers), so the callback should
+	// return quickly.
+	OnProgress func(TrainProgress)
+
 	// The documents within this corpus.
 	docs []Document
 
+	// docIndex[id] -> the index within docs of the (non-deleted) document with
+	// the specified Id.
+	docIndex map[int]int
+
+	// deleted[i] -> true if docs[i] has been removed, but not yet compacted
+	// away.
+	deleted map[int]bool
+
+	// df[t] -> the number of (non-deleted) documents that contain vocabulary
+	// term t.  The keys are the vocabulary determined by the last training, so
+	// a term remains in the map (with a df of 0) when every document that
+	// contained it has been removed.
+	df map[int]int
+
+	// The number of document changes made since the IDF values were last
+	// calculated.
+	staleUpdates int
+
 	// idf[t] -> the inverse document frequency of term t.
 	idf sparseHashVector
 
+	// The pivot that was used during the last training (see Pivot).
+	pivot float64
+
+	// Inverted index of the weighted document vectors.
+	index invertedIndex
+
+	// docNorms[i] -> the L2 norm of docs[i].TFIDF.
+	docNorms []float64
+
+	// maxWeights[t] -> upper bound of the contribution of term t to a score,
+	// per unit of query weight (see calcMaxWeights()).
+	maxWeights map[int]float64
+
+	// False if any document weight is negative, which disables query pruning.
+	nonNegativeWeights bool
+
+	// Pool of reusable query accumulators (see accumulate()).
+	accumulators *sync.Pool
+
 	// Whenever new documents are added to this corpus, the global stats need to
-	// be recalculated (via Recalculate()).  This flag keeps track of this state.
+	// be recalculated (via Train()).  This flag keeps track of this state.
 	needsRecalc bool
+
+	// True once Train() has been called, after which documents are indexed as
+	// they are added.
+	trained bool
+
+	// The statistics gathered by the last call to Train().
+	stats Stats
 }
 
 func NewTFIDF() *TFIDF {
 	return &TFIDF{
 		StopWordThreshold: 0.20,
+		Slope:             0.25,
+		MaxStaleFraction:  0.1,
 		docs:              make([]Document, 0, 200000),
+		docIndex:          make(map[int]int),
 		needsRecalc:       true,
 	}
 }
 
-// Saves this model to the specified file.
-func (me *TFIDF) Save(filePath string) error {
-	file, err := os.Create(filePath)
-	defer file.Close()
+// Adds a document to this corpus.  Returns ErrDuplicateDocId if the corpus
+// already contains a document with the same Id.
+//
+// Before the first call to Train(), the document is simply queued for training.
+// On a trained model, the document is indexed right away (see MaxStaleUpdates),
+// using the vocabulary that was determined by the last training: terms that
+// were not in the training corpus, or that were removed from it as stop words or
+// by pruning, are left out of its weighted vector until the next Train().
+// Documents must not be added concurrently with queries.
+func (me *TFIDF) AddDoc(docId int, doc vectors.SparseVector) error {
+	return me.AddDocWithAttributes(docId, doc, Attributes{})
+}
 
-	if err == nil {
-		encoder := gob.NewEncoder(file)
-		encoder.Encode(me.StopWordThreshold)
-		encoder.Encode(len(me.docs))
-		for i := 0; i < len(me.docs); i++ {
-			encoder.Encode(&me.docs[i])
-		}
+// Does what AddDoc() does, and sets the attributes of the document, which
+// queries can filter on (see QueryOptions.Filter).
+func (me *TFIDF) AddDocWithAttributes(docId int, doc vectors.SparseVector, attrs Attributes) error {
+	if _, found := me.docIndex[docId]; found {
+		return ErrDuplicateDocId
+	}
+	if me.docIndex == nil {
+		me.docIndex = make(map[int]int)
 	}
 
-	return err
+	if !me.trained {
+		me.docIndex[docId] = len(me.docs)
+		me.docs = append(me.docs, Document{
+			Id:         docId,
+			TF:         doc,
+			Attributes: attrs,
+		})
+		me.needsRecalc = true
+		return nil
+	}
+
+	me.addTrainedDoc(docId, doc, attrs)
+	me.markStale()
+	return nil
 }
 
-// Loads a TFIDF model from a saved image on file.
-func LoadTFIDF(filePath string) (*TFIDF, error) {
-	file, err := os.Open(filePath)
+// Trains the model. Returns a list of the distinct terms and their
+// corresponding document frequency (sorted by increasing frequency).
+func (me *TFIDF) Train() Stats {
+	stats, err := me.TrainContext(context.Background())
 	if err != nil {
-		return nil, err
+		panic(err.Error())
 	}
-	defer file.Close()
-
-	decoder := gob.NewDecoder(file)
+	return stats
+}
 
-	var stopWordThreshold float64
-	if err := decoder.Decode(&stopWordThreshold); err != nil {
-		return nil, err
+// Does what Train() does, but gives up and returns ctx.Err() as soon as ctx is
+// done.  A cancelled training leaves the model exactly as it was before the
+// call.  Returns an error rather than panicking if Scheme is not valid.
+func (me *TFIDF) TrainContext(ctx context.Context) (Stats, error) {
+	if err := me.Scheme.validate(); err != nil {
+		return Stats{}, err
 	}
+	me.compact()
 
-	var docCount int
-	if err := decoder.Decode(&docCount); err != nil {
-		return nil, err
+	// Training replaces the vectors of the documents rather than modifying
+	// them, so shallow copies are enough to roll back a cancelled training.
+	saved := *me
+	savedDocs := append([]Document(nil), me.docs...)
+
+	stats, err := me.train(ctx)
+	if err != nil {
+		*me = saved
+		copy(me.docs, savedDocs)
+		return Stats{}, err
 	}
+	return stats, nil
+}
 
-	docs := make([]Document, 0, docCount)
-	for i := 0; i < docCount; i++ {
-		var doc Document
-		if err := decoder.Decode(&doc); err != nil {
-			return nil, err
+func (me *TFIDF) train(ctx context.Context) (Stats, error) {
+	logger := me.logger()
+	tracker := newTrainTracker(ctx, me.OnProgress)
+	var durations PhaseDurations
+	trainStartTime := time.Now()
+
+	logger.Info("Calculating document frequencies", "docs", len(me.docs))
+	startTime := time.Now()
+	if err := tracker.startPhase(PhaseDocFrequencies, len(me.docs)); err != nil {
+		return Stats{}, err
+	}
+	df := calcDocFrequencies(me.docs, me.Workers, tracker)
+	if err := tracker.endPhase(); err != nil {
+		return Stats{}, err
+	}
+	rareTermCount := 0
+	for _, docFreq := range df {
+		if docFreq == 1 {
+			rareTermCount++
 		}
-		docs = append(docs, doc)
 	}
+	durations.DocFrequencies = time.Since(startTime)
+	logger.Info("Calculated document frequencies", "terms", len(df), "duration", durations.DocFrequencies)
 
-	return &TFIDF{
-		StopWordThreshold: stopWordThreshold,
-		docs:              docs,
-		needsRecalc:       true,
-	}, nil
-}
+	logger.Info("Removing stop words from document frequency map")
+	startTime = time.Now()
+	protectedTerms := make(map[int]bool, len(me.ProtectedTermIds))
+	for _, termId := range me.ProtectedTermIds {
+		protectedTerms[termId] = true
+	}
 
-func (me *TFIDF) AddDoc(docId int, doc vectors.SparseVector) {
-	me.docs = append(me.docs, Document{
-		Id: docId,
-		TF: doc,
-	})
-	me.needsRecalc = true
-}
+	listedStopWords := removeListedStopWords(df, me.StopWordIds)
+	stopWords := removeStopWords(df, len(me.docs), me.StopWordThreshold, protectedTerms)
 
-// Trains the model. Returns a list of the distinct terms and their
-// corresponding document frequency (sorted by increasing frequency).
-func (me *TFIDF) Train() Stats {
-	logger.Printf("Calculating document frequencies")
-	startTime := time.Now()
-	df := calcDocFrequencies(me.docs)
-	logger.Printf("Document frequency calculation took %v.", time.Since(startTime))
+	stopWordRules := make(map[int]RemovalRule, len(listedStopWords)+len(stopWords))
+	for _, stopWord := range listedStopWords {
+		stopWordRules[stopWord.Id] = RemovedByStopWordList
+	}
+	for _, stopWord := range stopWords {
+		stopWordRules[stopWord.Id] = RemovedByStopWordThreshold
+	}
+	stopWords = append(listedStopWords, stopWords...)
+	durations.StopWords = time.Since(startTime)
+	logger.Info("Removed stop words", "stopWords", len(stopWords), "duration", durations.StopWords)
 
-	logger.Printf("Removing stop words from document frequency map")
+	logger.Info("Pruning vocabulary")
 	startTime = time.Now()
-	stopWords := removeStopWords(df, len(me.docs), me.StopWordThreshold)
-	logger.Printf("%v stop words removed in %v.", len(stopWords), time.Since(startTime))
+	prunedTerms := removeRareTerms(df, absoluteDocFreq(me.MinDocFreq, len(me.docs)))
+	if me.MaxDocFreq > 0 {
+		prunedTerms = append(prunedTerms, removeFrequentTerms(df, absoluteDocFreq(me.MaxDocFreq, len(me.docs)), protectedTerms)...)
+	}
+	if me.MaxVocabulary > 0 {
+		prunedTerms = append(prunedTerms, limitVocabulary(df, me.MaxVocabulary)...)
+	}
+	durations.Pruning = time.Since(startTime)
+	logger.Info("Pruned vocabulary", "prunedTerms", len(prunedTerms), "duration", durations.Pruning)
 
-	logger.Printf("Filtering document vectors based on reduced document frequency map")
+	logger.Info("Filtering document vectors based on reduced document frequency map")
 	startTime = time.Now()
-	filterDocVectors(me.docs, df)
-	logger.Printf("Document vector filtering took %v.", time.Since(startTime))
+	if err := tracker.startPhase(PhaseFiltering, len(me.docs)); err != nil {
+		return Stats{}, err
+	}
+	trackedParallelFor(len(me.docs), me.Workers, tracker, func(_, lo, hi int) {
+		filterDocVectors(me.docs[lo:hi], df)
+	})
+	if err := tracker.endPhase(); err != nil {
+		return Stats{}, err
+	}
+	durations.Filtering = time.Since(startTime)
+	logger.Info("Filtered document vectors", "duration", durations.Filtering)
 
-	logger.Printf("Calculating IDF values for %v terms.", len(df))
+	me.df = df
+
+	logger.Info("Calculating IDF values", "terms", len(df))
+	startTime = time.Now()
+	me.calcIDF()
+	durations.IDF = time.Since(startTime)
+	logger.Info("Calculated IDF values", "duration", durations.IDF)
+
+	logger.Info("Calculating TF-IDF values")
 	startTime = time.Now()
-	me.idf = make(sparseHashVector, len(df))
-	totalDocs := float64(len(me.docs))
-	for termId, df := range df {
-		me.idf[termId] = 1.0 + math.Log(totalDocs/float64(df))
+	if err := tracker.startPhase(PhaseWeighting, len(me.docs)); err != nil {
+		return Stats{}, err
 	}
-	logger.Printf("IDF calculation took %v.", time.Since(startTime))
+	me.weighDocs(tracker)
+	if err := tracker.endPhase(); err != nil {
+		return Stats{}, err
+	}
+	durations.Weighting = time.Since(startTime)
+	logger.Info("Calculated TF-IDF values", "duration", durations.Weighting)
 
-	logger.Printf("Calculating TF-IDF values")
+	logger.Info("Building inverted index")
 	startTime = time.Now()
-	for i := 0; i < len(me.docs); i++ {
-		doc := &me.docs[i]
-		doc.TFIDF = calcTFIDF(doc.TF, me.idf)
+	if err := tracker.startPhase(PhaseIndexing, len(me.docs)); err != nil {
+		return Stats{}, err
+	}
+	me.rebuildIndex()
+	if err := tracker.endPhase(); err != nil {
+		return Stats{}, err
 	}
-	logger.Printf("TF-IDF calculation took %v.", time.Since(startTime))
+	me.staleUpdates = 0
+	durations.Indexing = time.Since(startTime)
+	logger.Info("Built inverted index", "terms", len(me.index), "duration", durations.Indexing)
 
+	me.trained = true
 	me.needsRecalc = false
-	return Stats{
-		DocumentCount: len(me.docs),
-		TermCount:     len(df),
+	durations.Total = time.Since(trainStartTime)
+
+	me.stats = Stats{
 		StopWords:     stopWords,
+		StopWordRules: stopWordRules,
+		PrunedTerms:   prunedTerms,
+		RareTermCount: rareTermCount,
+		Durations:     durations,
 	}
+	me.updateCorpusStats()
+	return me.stats, nil
+}
+
+// Updates the stats that describe the current state of the corpus, rather than
+// the training: the document and term counts, the document lengths, the
+// document frequency histogram and the memory estimate.
+func (me *TFIDF) updateCorpusStats() {
+	docLengths := make([]int, len(me.docs))
+	emptyDocCount := 0
+	for i := range me.docs {
+		docLengths[i] = len(me.vocabularyTerms(me.docs[i].TF))
+		if me.docNorms[i] == 0 {
+			emptyDocCount++
+		}
+	}
+
+	termCount := 0
+	for _, docFreq := range me.df {
+		if docFreq > 0 {
+			termCount++
+		}
+	}
+
+	me.stats.DocumentCount = len(me.docs)
+	me.stats.TermCount = termCount
+	me.stats.EmptyDocCount = emptyDocCount
+	me.stats.DocLengths = calcDistribution(docLengths)
+	me.stats.DocFreqHistogram = docFreqHistogram(me.df)
+	me.stats.Memory = me.estimateMemory()
+}
+
+func (me *TFIDF) logger() Logger {
+	if me.Logger == nil {
+		return defaultLogger
+	}
+	return me.Logger
+}
+
+// Returns the statistics gathered by the last call to Train().  The stop
+// words, pruned terms, RareTermCount and Durations describe that training.
+// The other fields describe the corpus, and are updated whenever the corpus is
+// reindexed after documents have been added, replaced or removed (see
+// MaxStaleUpdates), so they do not reflect the changes made since then.
+func (me *TFIDF) Stats() Stats {
+	return me.stats
 }
 
 // Calculates a similarity score indicating how similar documents doc1 and doc2
 // to each other.
 //
-// Returns a score in the range [0.0..1.0], where 1.0 means the documents are
-// identical.
+// Under the default weighting scheme (and any SMART scheme with cosine
+// normalization), returns a score in the range [0.0..1.0], where 1.0 means the
+// documents are identical.  Under other SMART schemes, returns the dot product
+// of the weighted document vectors.
+//
+// Panics if the model has not been trained.  Returns 0 if either document is
+// empty or has no weighted terms (see TryCalcSimilarity()).
 func (me *TFIDF) CalcSimilarity(doc1, doc2 vectors.SparseVector) float64 {
-	me.validateState()
+	score, err := me.TryCalcSimilarity(doc1, doc2)
+	if err == ErrNotTrained {
+		panic(err.Error())
+	}
+	return score
+}
 
-	// Calculate cosine similarity
-	doc1_tfidf := calcTFIDF(doc1, me.idf)
-	doc2_tfidf := calcTFIDF(doc2, me.idf)
-	score := vectors.Dot(doc1_tfidf, doc2_tfidf) / (vectors.Norm(doc1_tfidf) * vectors.Norm(doc2_tfidf))
-	return math.Min(1.0, score)
+// Does what CalcSimilarity() does, but returns an error instead of panicking:
+// ErrNotTrained if the model has not been trained, ErrEmptyQuery if either
+// document is empty, or ErrZeroNorm if either weighted document vector is a
+// zero vector (e.g. because none of its terms are in the corpus vocabulary).
+func (me *TFIDF) TryCalcSimilarity(doc1, doc2 vectors.SparseVector) (float64, error) {
+	if err := me.checkState(); err != nil {
+		return 0, err
+	}
+	if len(doc1) == 0 || len(doc2) == 0 {
+		return 0, ErrEmptyQuery
+	}
+
+	doc1_tfidf := me.weigh(doc1)
+	doc2_tfidf := me.weigh(doc2)
+	norm1, norm2 := vectors.Norm(doc1_tfidf), vectors.Norm(doc2_tfidf)
+	if norm1 == 0 || norm2 == 0 {
+		return 0, ErrZeroNorm
+	}
+	return me.score(vectors.Dot(doc1_tfidf, doc2_tfidf), norm1, norm2), nil
 }
 
 // Ranks the documents in the corpus in terms of how similar they are to the
-// specified query.
+// specified query.  Every non-empty document is returned, including those that
+// share no term with the query (which score 0), and documents with equal scores
+// are returned in the order in which they were added.  Use Search() to only
+// retrieve the documents with a score > 0.
+//
+// Panics if the model has not been trained.
 func (me *TFIDF) SimilarDocsForText(query vectors.SparseVector) []ScoredItem {
-	me.validateState()
+	rankedDocs, err := me.TrySimilarDocsForText(query)
+	if err == ErrNotTrained {
+		panic(err.Error())
+	}
+	if err != nil {
+		return []ScoredItem{}
+	}
+	return rankedDocs
+}
 
-	queryTFIDF := calcTFIDF(query, me.idf)
-	normQueryTFIDF := vectors.Norm(queryTFIDF)
+// Does what SimilarDocsForText() does, but returns an error instead of
+// panicking: ErrNotTrained if the model has not been trained, ErrEmptyQuery if
+// the query is empty, or ErrZeroNorm if none of the query's terms carry any
+// weight within the corpus.
+func (me *TFIDF) TrySimilarDocsForText(query vectors.SparseVector) ([]ScoredItem, error) {
+	queryTFIDF, err := me.weighQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return me.rankDocs(queryTFIDF), nil
+}
 
-	rankedDocs := []ScoredItem{}
-	for i := 0; i < len(me.docs); i++ {
-		doc := &me.docs[i]
+// Weighs the specified query vector.  Returns ErrNotTrained if the model has
+// not been trained, ErrEmptyQuery if the query is empty, or ErrZeroNorm if the
+// weighted query is a zero vector (in which case no document can match it).
+func (me *TFIDF) weighQuery(query vectors.SparseVector) (vectors.SparseVector, error) {
+	if err := me.checkState(); err != nil {
+		return nil, err
+	}
+	if len(query) == 0 {
+		return nil, ErrEmptyQuery
+	}
 
-		if len(doc.TFIDF) > 0 {
-			score := vectors.Dot(queryTFIDF, doc.TFIDF) / (normQueryTFIDF * vectors.Norm(doc.TFIDF))
-			score = math.Min(1.0, score)
-			rankedDocs = append(rankedDocs, ScoredItem{Id: doc.Id, Score: score})
-		}
+	queryTFIDF := me.weigh(query)
+	if vectors.Norm(queryTFIDF) == 0 {
+		return nil, ErrZeroNorm
 	}
+	return queryTFIDF, nil
+}
 
-	sort.Sort(byScore(rankedDocs))
-	return rankedDocs
+// Calculates the weighted vector of the specified term frequency vector.
+func (me *TFIDF) weigh(tf vectors.SparseVector) vectors.SparseVector {
+	return me.Scheme.weigh(tf, me.idf, me.pivot, me.Slope)
+}
+
+// Calculates the similarity score of 2 weighted vectors, given their dot product
+// and norms (see SMART.score()).
+func (me *TFIDF) score(dot, norm1, norm2 float64) float64 {
+	return me.Scheme.score(dot, norm1, norm2)
 }
 
-// Call this method to ensure the corpus is in state that it can be queried.
-func (me *TFIDF) validateState() {
+// Returns ErrNotTrained unless the corpus is in a state that it can be queried.
+func (me *TFIDF) checkState() error {
 	if me.needsRecalc {
-		panic("Corpus stats need to be recalculated.  Call Recalculate().")
+		return ErrNotTrained
 	}
+	return nil
 }
 
 // Calculates the document frequency (df) for each distinct term within the
 // specified corpus.  Returns a map df[t], where t is a term ID, and df[t]
 // returns the number of documents in the corpus that contain at least one
 // mention of t.
-func calcDocFrequencies(corpus []Document) map[int]int {
-	df := make(map[int]int, 1000000)
-
-	for i := 0; i < len(corpus); i++ {
-		doc := &corpus[i]
-		for j := 0; j < len(doc.TF); j++ {
-			term := &doc.TF[j]
-			df[term.Id] += 1
+//
+// The corpus is split among the specified number of workers, each of which
+// counts its share of the documents into a separate map.  Progress is reported
+// to the tracker, which may be nil.
+func calcDocFrequencies(corpus []Document, workers int, tracker *trainTracker) map[int]int {
+	partialDfs := make([]map[int]int, numChunks(len(corpus), workers))
+	for worker := range partialDfs {
+		partialDfs[worker] = make(map[int]int, 100000)
+	}
+	trackedParallelFor(len(corpus), workers, tracker, func(worker, lo, hi int) {
+		df := partialDfs[worker]
+		for i := lo; i < hi; i++ {
+			doc := &corpus[i]
+			for j := 0; j < len(doc.TF); j++ {
+				term := &doc.TF[j]
+				df[term.Id] += 1
+			}
+		}
+	})
+
+	df := partialDfs[0]
+	for _, partialDf := range partialDfs[1:] {
+		for termId, count := range partialDf {
+			df[termId] += count
 		}
 	}
 
@@ -270,11 +702,12 @@ func calcTFIDF(termFreqs vectors.SparseVector, idfs sparseHashVector) vectors.Sp
 
 // Identifies stopwords within the specified docFreqs map and then removes them.
 // A stopword is defined as a word that is present in more than 'threshold' %
-// of the documents in the corpus.
-func removeStopWords(docFreqs map[int]int, numDocs int, threshold float64) []vectors.Element {
+// of the documents in the corpus.  Terms in the protected set are never
+// considered stopwords.
+func removeStopWords(docFreqs map[int]int, numDocs int, threshold float64, protected map[int]bool) []vectors.Element {
 	stopWords := make([]vectors.Element, 0, 100000)
 	for termId, docFreq := range docFreqs {
-		isStopWord := (float64(docFreq) / float64(numDocs)) > threshold
+		isStopWord := (float64(docFreq)/float64(numDocs)) > threshold && !protected[termId]
 		if isStopWord {
 			delete(docFreqs, termId)
 			stopWords = append(stopWords, vectors.Element{Id: termId, Value: float64(docFreq)})
@@ -284,22 +717,86 @@ func removeStopWords(docFreqs map[int]int, numDocs int, threshold float64) []vec
 	return stopWords
 }
 
-// Identifies and removes "unimportant" terms within the given document
-// frequency map.
-func removeUnimportantTerms(docFreqs map[int]int) []vectors.Element {
-	removedTerms := make([]vectors.Element, 0, 100000)
+// Removes the specified stopword term Ids from the docFreqs map.  Returns the
+// removed terms (stopword Ids that are not in the map are ignored).
+func removeListedStopWords(docFreqs map[int]int, stopWordIds []int) []vectors.Element {
+	stopWords := make([]vectors.Element, 0, len(stopWordIds))
+	for _, termId := range stopWordIds {
+		if docFreq, found := docFreqs[termId]; found {
+			delete(docFreqs, termId)
+			stopWords = append(stopWords, vectors.Element{Id: termId, Value: float64(docFreq)})
+		}
+	}
+
+	return stopWords
+}
+
+// Converts a MinDocFreq/MaxDocFreq setting into an absolute document count.
+func absoluteDocFreq(value float64, numDocs int) float64 {
+	if value < 1.0 {
+		return value * float64(numDocs)
+	}
+	return value
+}
+
+// Identifies and removes terms within the given document frequency map that are
+// present in fewer than minDocFreq documents.
+func removeRareTerms(docFreqs map[int]int, minDocFreq float64) []RemovedTerm {
+	removedTerms := make([]RemovedTerm, 0, 1000)
 
 	for termId, docFreq := range docFreqs {
-		isRareInCorpus := (docFreq <= 1)
+		isRareInCorpus := (float64(docFreq) < minDocFreq)
 		if isRareInCorpus {
 			delete(docFreqs, termId)
-			removedTerms = append(removedTerms, vectors.Element{Id: termId, Value: float64(docFreq)})
+			removedTerms = append(removedTerms, RemovedTerm{Id: termId, DocFreq: docFreq, Rule: RemovedByMinDocFreq})
 		}
 	}
 
 	return removedTerms
 }
 
+// Identifies and removes terms within the given document frequency map that are
+// present in more than maxDocFreq documents, except for protected terms.
+func removeFrequentTerms(docFreqs map[int]int, maxDocFreq float64, protected map[int]bool) []RemovedTerm {
+	removedTerms := make([]RemovedTerm, 0, 1000)
+
+	for termId, docFreq := range docFreqs {
+		if float64(docFreq) > maxDocFreq && !protected[termId] {
+			delete(docFreqs, termId)
+			removedTerms = append(removedTerms, RemovedTerm{Id: termId, DocFreq: docFreq, Rule: RemovedByMaxDocFreq})
+		}
+	}
+
+	return removedTerms
+}
+
+// Removes all but the maxTerms terms with the highest document frequency from
+// the given document frequency map.
+func limitVocabulary(docFreqs map[int]int, maxTerms int) []RemovedTerm {
+	if len(docFreqs) <= maxTerms {
+		return []RemovedTerm{}
+	}
+
+	terms := make([]vectors.Element, 0, len(docFreqs))
+	for termId, docFreq := range docFreqs {
+		terms = append(terms, vectors.Element{Id: termId, Value: float64(docFreq)})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Value != terms[j].Value {
+			return terms[i].Value > terms[j].Value
+		}
+		return terms[i].Id < terms[j].Id
+	})
+
+	removedTerms := make([]RemovedTerm, 0, len(terms)-maxTerms)
+	for _, term := range terms[maxTerms:] {
+		delete(docFreqs, term.Id)
+		removedTerms = append(removedTerms, RemovedTerm{Id: term.Id, DocFreq: int(term.Value), Rule: RemovedByMaxVocabulary})
+	}
+
+	return removedTerms
+}
+
 // For each document in []docs, keeps *only* the term Ids within that document's
 // term frequency vector (doc.tf) that are present in the provided termLookup
 // map.  The keys in the termLookup map represent the term Ids, and the values
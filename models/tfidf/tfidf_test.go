@@ -77,6 +77,7 @@ func TestSaveAndLoadTFIDF(t *testing.T) {
 	assert.Nil(t, loadErr)
 	assert.Equal(t, reloadedModel.StopWordThreshold, model.StopWordThreshold)
 	assert.Equal(t, reloadedModel.docs, model.docs)
+	assert.Equal(t, ErrDuplicateDocId, reloadedModel.AddDoc(123, vectors.SparseVector{}))
 }
 
 func TestLoadTFIDF_nonexistentFile(t *testing.T) {
@@ -115,6 +116,17 @@ func TestTFIDF_AddDoc(t *testing.T) {
 	assert.True(t, c.needsRecalc)
 }
 
+func TestTFIDF_AddDoc_duplicateId(t *testing.T) {
+	c := newUntrainedTestModel(SMART{}, []vectors.SparseVector{{{Id: 1, Value: 1}}})
+	assert.Equal(t, ErrDuplicateDocId, c.AddDoc(0, vectors.SparseVector{{Id: 2, Value: 1}}))
+	assert.Equal(t, 1, len(c.docs))
+
+	c.Train()
+	assert.Equal(t, ErrDuplicateDocId, c.AddDoc(0, vectors.SparseVector{{Id: 2, Value: 1}}))
+	assert.Nil(t, c.AddDoc(1, vectors.SparseVector{{Id: 2, Value: 1}}))
+	assert.Equal(t, 2, len(c.docs))
+}
+
 func TestTFIDF_CalcSimilarity(t *testing.T) {
 	corpus := []string{
 		"apache helicopter military war", // docId=0
@@ -144,6 +156,56 @@ func TestTFIDF_CalcSimilarity(t *testing.T) {
 	assert.Equal(t, 0.0, model.CalcSimilarity(vectorizedDocs[0], vectorizedDocs[3]))
 }
 
+func TestTFIDF_queryErrors(t *testing.T) {
+	model := newUntrainedTestModel(SMART{}, []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}},
+		{{Id: 2, Value: 1}},
+	})
+	query := vectors.SparseVector{{Id: 1, Value: 1}}
+
+	_, err := model.TryCalcSimilarity(query, query)
+	assert.Equal(t, ErrNotTrained, err)
+	_, err = model.TrySimilarDocsForText(query)
+	assert.Equal(t, ErrNotTrained, err)
+	_, err = model.TrySearch(query, QueryOptions{K: 1})
+	assert.Equal(t, ErrNotTrained, err)
+	assert.PanicsWithValue(t, ErrNotTrained.Error(), func() { model.SimilarDocsForText(query) })
+	assert.PanicsWithValue(t, ErrNotTrained.Error(), func() { model.CalcSimilarity(query, query) })
+	assert.PanicsWithValue(t, ErrNotTrained.Error(), func() { model.Search(query, QueryOptions{}) })
+
+	model.Train()
+
+	_, err = model.TrySimilarDocsForText(vectors.SparseVector{})
+	assert.Equal(t, ErrEmptyQuery, err)
+	_, err = model.TryCalcSimilarity(query, nil)
+	assert.Equal(t, ErrEmptyQuery, err)
+
+	unknownTerms := vectors.SparseVector{{Id: 99, Value: 1}}
+	_, err = model.TrySimilarDocsForText(unknownTerms)
+	assert.Equal(t, ErrZeroNorm, err)
+	_, err = model.TrySearch(unknownTerms, QueryOptions{K: 1})
+	assert.Equal(t, ErrZeroNorm, err)
+	_, err = model.TryCalcSimilarity(query, unknownTerms)
+	assert.Equal(t, ErrZeroNorm, err)
+
+	// The panicking variants return empty results rather than NaN scores.
+	assert.Equal(t, 0.0, model.CalcSimilarity(query, unknownTerms))
+	assert.Equal(t, 0.0, model.CalcSimilarity(query, nil))
+	assert.Equal(t, []ScoredItem{}, model.SimilarDocsForText(unknownTerms))
+	assert.Equal(t, []ScoredItem{}, model.Search(unknownTerms, QueryOptions{K: 1}))
+
+	items, err := model.TrySearch(query, QueryOptions{K: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0}, scoredItemIds(items))
+}
+
+func TestTFIDF_score_zeroNorm(t *testing.T) {
+	model := NewTFIDF()
+	assert.Equal(t, 0.0, model.score(0, 0, 1))
+	assert.Equal(t, 0.0, model.score(0, 1, 0))
+	assert.Equal(t, 0.5, model.score(1, 1, 2))
+}
+
 // This is just a sanity check
 func TestTFIDF_SimilarDocsForText(t *testing.T) {
 	corpus := []string{
@@ -193,7 +255,7 @@ func TestCalcDocFrequencies(t *testing.T) {
 		},
 	}
 
-	assert.Equal(t, map[int]int{10: 1, 20: 2, 30: 3}, calcDocFrequencies(docs))
+	assert.Equal(t, map[int]int{10: 1, 20: 2, 30: 3}, calcDocFrequencies(docs, 1, nil))
 }
 
 func TestRemoveStopWords(t *testing.T) {
@@ -205,12 +267,48 @@ func TestRemoveStopWords(t *testing.T) {
 		4: 4,
 		5: 5,
 	}
-	removedTerms := removeStopWords(docFreqs, docCount, 0.20)
+	removedTerms := removeStopWords(docFreqs, docCount, 0.20, nil)
 	assert.Equal(t, map[int]int{1: 1, 2: 2}, docFreqs)
 	assert.Equal(t, 3, len(removedTerms))
 }
 
-func TestRemoveUnimportantTerms(t *testing.T) {
+func TestRemoveStopWords_protected(t *testing.T) {
+	docFreqs := map[int]int{1: 1, 2: 5, 3: 9}
+	removedTerms := removeStopWords(docFreqs, 10, 0.20, map[int]bool{3: true})
+	assert.Equal(t, map[int]int{1: 1, 3: 9}, docFreqs)
+	assert.Equal(t, []vectors.Element{{Id: 2, Value: 5}}, removedTerms)
+}
+
+func TestRemoveListedStopWords(t *testing.T) {
+	docFreqs := map[int]int{1: 1, 2: 2, 3: 3}
+	removedTerms := removeListedStopWords(docFreqs, []int{3, 1, 99})
+	assert.Equal(t, map[int]int{2: 2}, docFreqs)
+	assert.Equal(t, []vectors.Element{{Id: 3, Value: 3}, {Id: 1, Value: 1}}, removedTerms)
+}
+
+func TestTFIDF_Train_stopWordLists(t *testing.T) {
+	docs := []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 3, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 4, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 5, Value: 1}},
+	}
+
+	model := newUntrainedTestModel(SMART{}, docs)
+	model.StopWordThreshold = 0.5       // terms 1 and 2 exceed the threshold...
+	model.ProtectedTermIds = []int{2}   // ...but term 2 is protected
+	model.StopWordIds = []int{5, 3, 99} // terms 3 and 5 are explicit stop words
+
+	stats := model.Train()
+	assert.Equal(t, 2, stats.TermCount)
+	assert.Equal(t, []vectors.Element{{Id: 5, Value: 1}, {Id: 3, Value: 1}, {Id: 1, Value: 3}}, stats.StopWords)
+	assert.Equal(t,
+		map[int]RemovalRule{1: RemovedByStopWordThreshold, 3: RemovedByStopWordList, 5: RemovedByStopWordList},
+		stats.StopWordRules,
+	)
+	assert.Equal(t, vectors.SparseVector{{Id: 2, Value: 1}, {Id: 4, Value: 1}}, model.docs[1].TF)
+}
+
+func TestRemoveRareTerms(t *testing.T) {
 	docFreqs := map[int]int{
 		1: 1,
 		2: 222,
@@ -218,9 +316,104 @@ func TestRemoveUnimportantTerms(t *testing.T) {
 		4: 444,
 		5: 555,
 	}
-	removedTerms := removeUnimportantTerms(docFreqs)
+	removedTerms := removeRareTerms(docFreqs, 2)
 	assert.Equal(t, map[int]int{2: 222, 4: 444, 5: 555}, docFreqs)
 	assert.Equal(t, 2, len(removedTerms))
+	for _, term := range removedTerms {
+		assert.Equal(t, 1, term.DocFreq)
+		assert.Equal(t, RemovedByMinDocFreq, term.Rule)
+	}
+}
+
+func TestRemoveFrequentTerms(t *testing.T) {
+	docFreqs := map[int]int{1: 1, 2: 2, 3: 3}
+	removedTerms := removeFrequentTerms(docFreqs, 1, map[int]bool{2: true})
+	assert.Equal(t, map[int]int{1: 1, 2: 2}, docFreqs)
+	assert.Equal(t, []RemovedTerm{{Id: 3, DocFreq: 3, Rule: RemovedByMaxDocFreq}}, removedTerms)
+}
+
+func TestLimitVocabulary(t *testing.T) {
+	docFreqs := map[int]int{1: 5, 2: 9, 3: 5, 4: 1, 5: 5}
+	removedTerms := limitVocabulary(docFreqs, 3)
+	assert.Equal(t, map[int]int{1: 5, 2: 9, 3: 5}, docFreqs)
+	assert.Equal(t,
+		[]RemovedTerm{
+			{Id: 5, DocFreq: 5, Rule: RemovedByMaxVocabulary},
+			{Id: 4, DocFreq: 1, Rule: RemovedByMaxVocabulary},
+		},
+		removedTerms,
+	)
+
+	assert.Equal(t, []RemovedTerm{}, limitVocabulary(docFreqs, 3))
+}
+
+func TestAbsoluteDocFreq(t *testing.T) {
+	assert.Equal(t, 0.0, absoluteDocFreq(0, 200))
+	assert.Equal(t, 20.0, absoluteDocFreq(0.1, 200))
+	assert.Equal(t, 5.0, absoluteDocFreq(5, 200))
+
+	// 1 is an absolute count, unlike StopWordThreshold where it means 100%.
+	assert.Equal(t, 1.0, absoluteDocFreq(1, 200))
+	assert.Equal(t, 1.5, absoluteDocFreq(1.5, 200))
+	assert.Equal(t, 198.0, absoluteDocFreq(0.99, 200))
+}
+
+// MinDocFreq = 1 keeps every term, while MaxDocFreq = 1 prunes the terms that
+// are present in more than one document.
+func TestTFIDF_Train_docFreqOfOne(t *testing.T) {
+	model := newUntrainedTestModel(SMART{}, []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 3, Value: 1}},
+	})
+	model.MinDocFreq = 1.0
+	model.MaxDocFreq = 1.0
+
+	stats := model.Train()
+	assert.Equal(t, 2, stats.TermCount)
+	assert.Equal(t, []RemovedTerm{{Id: 1, DocFreq: 2, Rule: RemovedByMaxDocFreq}}, stats.PrunedTerms)
+}
+
+func TestTFIDF_Train_pruning(t *testing.T) {
+	docs := []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 3, Value: 1}, {Id: 4, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 3, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 5, Value: 1}},
+		{{Id: 1, Value: 1}, {Id: 6, Value: 1}},
+	}
+
+	model := newUntrainedTestModel(SMART{}, docs)
+	model.StopWordThreshold = 0.9 // term 1 (present in all docs) is a stop word
+	model.MinDocFreq = 2          // terms 4, 5 and 6 are rare
+	model.MaxDocFreq = 0.5        // term 2 (present in 3 of 4 docs) is too frequent
+
+	stats := model.Train()
+	assert.Equal(t, 1, stats.TermCount)
+	assert.Equal(t, []vectors.Element{{Id: 1, Value: 4}}, stats.StopWords)
+
+	sort.Slice(stats.PrunedTerms, func(i, j int) bool { return stats.PrunedTerms[i].Id < stats.PrunedTerms[j].Id })
+	assert.Equal(t,
+		[]RemovedTerm{
+			{Id: 2, DocFreq: 3, Rule: RemovedByMaxDocFreq},
+			{Id: 4, DocFreq: 1, Rule: RemovedByMinDocFreq},
+			{Id: 5, DocFreq: 1, Rule: RemovedByMinDocFreq},
+			{Id: 6, DocFreq: 1, Rule: RemovedByMinDocFreq},
+		},
+		stats.PrunedTerms,
+	)
+	assert.Equal(t, vectors.SparseVector{{Id: 3, Value: 1}}, model.docs[0].TF)
+}
+
+func TestTFIDF_Train_maxVocabulary(t *testing.T) {
+	model := newUntrainedTestModel(SMART{}, []vectors.SparseVector{
+		{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 3, Value: 1}},
+		{{Id: 2, Value: 1}, {Id: 3, Value: 1}},
+	})
+	model.MaxVocabulary = 2
+
+	stats := model.Train()
+	assert.Equal(t, 2, stats.TermCount)
+	assert.Equal(t, []RemovedTerm{{Id: 1, DocFreq: 1, Rule: RemovedByMaxVocabulary}}, stats.PrunedTerms)
+	assert.Equal(t, "MaxVocabulary", stats.PrunedTerms[0].Rule.String())
 }
 
 func TestFilterDocVectors(t *testing.T) {
@@ -255,3 +448,36 @@ func TestFilterDocVectors(t *testing.T) {
 		docs,
 	)
 }
+
+// Returns a model trained on the corpus (with stop word removal turned off),
+// in which each document's Id is its position within the corpus.
+func newTestModel(scheme SMART, corpus []vectors.SparseVector) *TFIDF {
+	model := newUntrainedTestModel(scheme, corpus)
+	model.Train()
+	return model
+}
+
+// Does what newTestModel() does, but leaves the model untrained, so that its
+// settings can still be changed before Train().
+func newUntrainedTestModel(scheme SMART, corpus []vectors.SparseVector) *TFIDF {
+	model := NewTFIDF()
+	model.Logger = NopLogger
+	model.StopWordThreshold = 1.0
+	model.Scheme = scheme
+	for docId, doc := range corpus {
+		model.AddDoc(docId, doc)
+	}
+	return model
+}
+
+// Does what newTestModel() does for a corpus of plain text documents, which
+// are tokenized and vectorized using the returned dictionary.
+func newTextTestModel(corpus []string) (*TFIDF, *gosim.Dictionary) {
+	dict := gosim.NewDictionary()
+	tokenize := gosim.MakeDefaultTokenizer()
+	docs := make([]vectors.SparseVector, len(corpus))
+	for docId, doc := range corpus {
+		docs[docId] = dict.VectorizeAndUpdate(tokenize(doc))
+	}
+	return newTestModel(SMART{}, docs), dict
+}
@@ -0,0 +1,250 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"sort"
+)
+
+// Removes the document with the specified Id from this corpus.  Returns
+// ErrUnknownDocId if the corpus contains no such document.
+//
+// On a trained model, the document frequencies of the document's terms are
+// updated right away, and the remaining documents are reweighed once enough
+// changes have accumulated (see MaxStaleUpdates).  Like AddDoc(), this method must
+// not be called concurrently with queries.
+func (me *TFIDF) RemoveDoc(docId int) error {
+	docIdx, found := me.docIndex[docId]
+	if !found {
+		return ErrUnknownDocId
+	}
+
+	if !me.trained {
+		me.markDeleted(docIdx)
+		return nil
+	}
+
+	me.removeTrainedDoc(docIdx)
+	me.markStale()
+	return nil
+}
+
+// Replaces the term frequency vector of the document with the specified Id,
+// keeping its attributes.  Returns ErrUnknownDocId if the corpus contains no
+// such document.  On a trained model, the replaced document ranks as if it had
+// just been added (i.e. after all other documents with the same score).
+func (me *TFIDF) ReplaceDoc(docId int, doc vectors.SparseVector) error {
+	docIdx, found := me.docIndex[docId]
+	if !found {
+		return ErrUnknownDocId
+	}
+
+	if !me.trained {
+		me.docs[docIdx].TF = doc
+		return nil
+	}
+
+	attrs := me.docs[docIdx].Attributes
+	me.removeTrainedDoc(docIdx)
+	me.addTrainedDoc(docId, doc, attrs)
+	me.markStale()
+	return nil
+}
+
+// Returns the number of documents in this corpus, excluding deleted ones.
+func (me *TFIDF) liveDocCount() int {
+	return len(me.docs) - len(me.deleted)
+}
+
+// Weighs the specified document using the current IDF values and adds it to
+// the inverted index.  Terms that are not in the vocabulary of the last
+// training are left out of the weighted vector, so that the vocabulary stays
+// subject to the stop word and pruning rules, but are kept in the document's
+// TF, so that the next Train() can take them into account.
+func (me *TFIDF) addTrainedDoc(docId int, tf vectors.SparseVector, attrs Attributes) {
+	filteredTF := me.vocabularyTerms(tf)
+
+	numDocs := me.liveDocCount() + 1
+	for _, term := range filteredTF {
+		me.df[term.Id]++
+		me.idf[term.Id] = me.Scheme.idf(me.df[term.Id], numDocs)
+	}
+
+	docIdx := len(me.docs)
+	me.docIndex[docId] = docIdx
+	me.docs = append(me.docs, Document{
+		Id:         docId,
+		TF:         tf,
+		TFIDF:      me.weigh(filteredTF),
+		Attributes: attrs,
+	})
+
+	doc := &me.docs[docIdx]
+	norm := vectors.Norm(doc.TFIDF)
+	me.docNorms = append(me.docNorms, norm)
+
+	// docIdx is larger than that of any indexed document, so appending keeps
+	// the postings sorted.
+	for _, term := range doc.TFIDF {
+		me.index[term.Id] = append(me.index[term.Id], posting{docIdx: docIdx, weight: term.Value})
+
+		weight := term.Value
+		if me.Scheme.isLegacy() {
+			weight /= norm
+		}
+		if weight > me.maxWeights[term.Id] {
+			me.maxWeights[term.Id] = weight
+		}
+		me.nonNegativeWeights = me.nonNegativeWeights && term.Value >= 0
+	}
+}
+
+// Removes the specified document from the inverted index and marks it as
+// deleted.  The document keeps its slot in me.docs until the next reindex(),
+// so that the docIdx of the other documents remains valid.
+//
+// The maximum term weights are left as they are, since they remain valid upper
+// bounds.
+func (me *TFIDF) removeTrainedDoc(docIdx int) {
+	doc := &me.docs[docIdx]
+
+	numDocs := me.liveDocCount() - 1
+	for _, term := range me.vocabularyTerms(doc.TF) {
+		me.df[term.Id]--
+		if me.df[term.Id] > 0 {
+			me.idf[term.Id] = me.Scheme.idf(me.df[term.Id], numDocs)
+		} else {
+			delete(me.idf, term.Id)
+		}
+	}
+
+	for _, term := range doc.TFIDF {
+		postings := me.index[term.Id]
+		i := sort.Search(len(postings), func(i int) bool { return postings[i].docIdx >= docIdx })
+		if i < len(postings) && postings[i].docIdx == docIdx {
+			postings = append(postings[:i], postings[i+1:]...)
+		}
+
+		if len(postings) > 0 {
+			me.index[term.Id] = postings
+		} else {
+			delete(me.index, term.Id)
+		}
+	}
+
+	me.markDeleted(docIdx)
+}
+
+// Marks the specified document as deleted.  The document keeps its slot in
+// me.docs until the next compact().
+func (me *TFIDF) markDeleted(docIdx int) {
+	if me.deleted == nil {
+		me.deleted = make(map[int]bool)
+	}
+	me.deleted[docIdx] = true
+	delete(me.docIndex, me.docs[docIdx].Id)
+	me.docs[docIdx] = Document{Id: me.docs[docIdx].Id}
+}
+
+// Records a change to the corpus, and reindexes it once more than
+// MaxStaleUpdates changes, and more than MaxStaleFraction of the corpus, have
+// accumulated.
+func (me *TFIDF) markStale() {
+	me.staleUpdates++
+	if me.staleUpdates > me.MaxStaleUpdates && float64(me.staleUpdates) > me.MaxStaleFraction*float64(me.liveDocCount()) {
+		me.reindex()
+	}
+}
+
+// Recalculates the IDF values from the current document frequencies, then
+// reweighs every document and rebuilds the inverted index.  The vocabulary is
+// left as it is: the stop word and pruning rules are only applied by Train().
+func (me *TFIDF) reindex() {
+	me.compact()
+	me.calcIDF()
+	me.weighDocs(nil)
+	me.rebuildIndex()
+	me.updateCorpusStats()
+	me.staleUpdates = 0
+}
+
+// Calculates the IDF values from the current document frequencies.
+func (me *TFIDF) calcIDF() {
+	me.idf = make(sparseHashVector, len(me.df))
+	for termId, df := range me.df {
+		if df > 0 {
+			me.idf[termId] = me.Scheme.idf(df, len(me.docs))
+		}
+	}
+}
+
+// Returns the terms of tf that are in the vocabulary (see TFIDF.df), or tf
+// itself if all of them are.
+func (me *TFIDF) vocabularyTerms(tf vectors.SparseVector) vectors.SparseVector {
+	for i, term := range tf {
+		if _, found := me.df[term.Id]; found {
+			continue
+		}
+
+		filtered := append(make(vectors.SparseVector, 0, len(tf)-1), tf[:i]...)
+		for _, term := range tf[i+1:] {
+			if _, found := me.df[term.Id]; found {
+				filtered = append(filtered, term)
+			}
+		}
+		return filtered
+	}
+	return tf
+}
+
+// Calculates the weighted vector of every document, using the current IDF
+// values.  Progress is reported to the tracker, which may be nil.
+func (me *TFIDF) weighDocs(tracker *trainTracker) {
+	me.pivot = me.Pivot
+	if me.pivot == 0 && len(me.docs) > 0 {
+		uniqueTerms := 0
+		for i := 0; i < len(me.docs); i++ {
+			uniqueTerms += len(me.vocabularyTerms(me.docs[i].TF))
+		}
+		me.pivot = float64(uniqueTerms) / float64(len(me.docs))
+	}
+
+	trackedParallelFor(len(me.docs), me.Workers, tracker, func(_, lo, hi int) {
+		for i := lo; i < hi; i++ {
+			doc := &me.docs[i]
+			doc.TFIDF = me.weigh(me.vocabularyTerms(doc.TF))
+		}
+	})
+}
+
+// Builds the inverted index, along with the document norms and term weight
+// bounds, from the weighted document vectors.
+func (me *TFIDF) rebuildIndex() {
+	me.index = buildIndex(me.docs, me.Workers)
+	me.docNorms = calcDocNorms(me.docs, me.Workers)
+	me.maxWeights, me.nonNegativeWeights = calcMaxWeights(me.index, me.docNorms, me.Scheme.isLegacy())
+	me.accumulators = newAccumulatorPool(len(me.docs))
+}
+
+// Drops the deleted documents from me.docs.  The inverted index must be rebuilt
+// afterwards, since the documents' positions change.
+func (me *TFIDF) compact() {
+	if len(me.deleted) == 0 {
+		return
+	}
+
+	docs := me.docs[:0]
+	for docIdx, doc := range me.docs {
+		if !me.deleted[docIdx] {
+			docs = append(docs, doc)
+		}
+	}
+	for i := len(docs); i < len(me.docs); i++ {
+		me.docs[i] = Document{}
+	}
+
+	me.docs = docs
+	me.deleted = nil
+	for docIdx, doc := range me.docs {
+		me.docIndex[doc.Id] = docIdx
+	}
+}
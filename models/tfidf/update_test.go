@@ -0,0 +1,265 @@
+package tfidf
+
+import (
+	"github.com/cet001/mathext/vectors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestTFIDF_AddDoc_afterTrain(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	corpus := makeRandomCorpus(rng, 300, 1000, 20)
+
+	for _, scheme := range []SMART{{}, {TF: 'l', IDF: 't', Norm: 'c'}, {TF: 'n', IDF: 'f', Norm: 'u'}} {
+		incremental := newUpdateTestModel(scheme, corpus[:200])
+		for docId := 200; docId < len(corpus); docId++ {
+			incremental.AddDoc(docId, corpus[docId])
+		}
+
+		// The terms that are new to the corpus are ignored by the incremental
+		// model.
+		inVocabulary := append([]vectors.SparseVector{}, corpus[:200]...)
+		for _, doc := range corpus[200:] {
+			filtered := vectors.SparseVector{}
+			for _, term := range doc {
+				if _, found := incremental.df[term.Id]; found {
+					filtered = append(filtered, term)
+				}
+			}
+			inVocabulary = append(inVocabulary, filtered)
+		}
+
+		retrained := newUpdateTestModel(scheme, inVocabulary)
+		assertSameRankings(t, rng, retrained, incremental, scheme.String())
+	}
+}
+
+func TestTFIDF_RemoveDoc(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	corpus := makeRandomCorpus(rng, 300, 1000, 20)
+
+	for _, scheme := range []SMART{{}, {TF: 'l', IDF: 't', Norm: 'c'}} {
+		incremental := newUpdateTestModel(scheme, corpus)
+		incremental.MaxStaleUpdates = 1000
+		for docId := 0; docId < len(corpus); docId += 3 {
+			assert.Nil(t, incremental.RemoveDoc(docId))
+		}
+		assert.Equal(t, ErrUnknownDocId, incremental.RemoveDoc(0))
+		assert.Equal(t, ErrUnknownDocId, incremental.RemoveDoc(12345))
+
+		// The corpus is stale, but the index must not return the removed
+		// documents.
+		for i := 0; i < 20; i++ {
+			query := incremental.weigh(makeRandomCorpus(rng, 1, 1000, 5)[0])
+			expected := incremental.rankDocsBruteForce(query)
+			assertSameRanking(t, incremental, expected, incremental.rankDocs(query), "")
+			for _, item := range expected {
+				assert.NotEqual(t, 0, item.Id%3)
+			}
+		}
+
+		incremental.MaxStaleUpdates = 0
+		incremental.AddDoc(len(corpus), corpus[0])
+
+		retrained := NewTFIDF()
+		retrained.StopWordThreshold = 1.0
+		retrained.Scheme = scheme
+		for docId, doc := range corpus {
+			if docId%3 != 0 {
+				retrained.AddDoc(docId, doc)
+			}
+		}
+		retrained.AddDoc(len(corpus), corpus[0])
+		retrained.Train()
+		assertSameRankings(t, rng, retrained, incremental, scheme.String())
+	}
+}
+
+func TestTFIDF_ReplaceDoc(t *testing.T) {
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	model.AddDoc(10, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}})
+	model.AddDoc(20, vectors.SparseVector{{Id: 3, Value: 1}})
+	assert.Nil(t, model.ReplaceDoc(20, vectors.SparseVector{{Id: 4, Value: 1}}))
+	assert.Equal(t, ErrUnknownDocId, model.ReplaceDoc(30, vectors.SparseVector{{Id: 4, Value: 1}}))
+	model.Train()
+
+	assert.Equal(t, []int{20}, scoredItemIds(model.Search(vectors.SparseVector{{Id: 4, Value: 1}}, QueryOptions{})))
+	assert.Equal(t, []int{10}, scoredItemIds(model.Search(vectors.SparseVector{{Id: 2, Value: 1}}, QueryOptions{})))
+
+	assert.Nil(t, model.ReplaceDoc(10, vectors.SparseVector{{Id: 4, Value: 2}}))
+	assert.Equal(t, []ScoredItem{}, model.SimilarDocsForText(vectors.SparseVector{{Id: 2, Value: 1}}))
+	// Both documents now score the same, and the replaced document ranks as if
+	// it had just been added.
+	assert.Equal(t, []int{20, 10}, scoredItemIds(model.SimilarDocsForText(vectors.SparseVector{{Id: 4, Value: 1}, {Id: 5, Value: 1}})))
+	assert.Equal(t, 2, len(model.docs))
+	assert.Equal(t, map[int]int{20: 0, 10: 1}, model.docIndex)
+}
+
+func TestTFIDF_RemoveDoc_beforeTrain(t *testing.T) {
+	model := NewTFIDF()
+	model.AddDoc(10, vectors.SparseVector{{Id: 1, Value: 1}})
+	model.AddDoc(20, vectors.SparseVector{{Id: 2, Value: 1}})
+	assert.Nil(t, model.RemoveDoc(10))
+	assert.Equal(t, ErrUnknownDocId, model.RemoveDoc(10))
+
+	// The Id of a removed document can be reused.
+	assert.Nil(t, model.AddDoc(10, vectors.SparseVector{{Id: 3, Value: 1}}))
+	assert.True(t, model.needsRecalc)
+
+	stats := model.Train()
+	assert.Equal(t, 2, stats.DocumentCount)
+	assert.Equal(t, []int{20, 10}, []int{model.docs[0].Id, model.docs[1].Id})
+	assert.Equal(t, map[int]int{20: 0, 10: 1}, model.docIndex)
+}
+
+// Verifies that the IDF values are only recalculated once more than
+// MaxStaleUpdates changes have been made, and that queries keep working in the
+// meantime.
+func TestTFIDF_MaxStaleUpdates(t *testing.T) {
+	model := NewTFIDF()
+	model.StopWordThreshold = 1.0
+	model.MaxStaleUpdates = 2
+	model.AddDoc(10, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}})
+	model.AddDoc(20, vectors.SparseVector{{Id: 1, Value: 1}})
+	model.AddDoc(25, vectors.SparseVector{{Id: 3, Value: 2}})
+	model.Train()
+	idf2 := model.idf[2]
+
+	// The IDF values of the new document's terms are updated right away.
+	model.AddDoc(30, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 3, Value: 1}})
+	assert.Equal(t, 1, model.staleUpdates)
+	assert.Equal(t, idf2, model.idf[2])
+	assert.Equal(t, model.Scheme.idf(3, 4), model.idf[1])
+	assert.Equal(t, model.Scheme.idf(2, 4), model.idf[3])
+	assert.Equal(t, []int{25, 30}, scoredItemIds(model.Search(vectors.SparseVector{{Id: 3, Value: 1}}, QueryOptions{})))
+
+	assert.Nil(t, model.RemoveDoc(20))
+	assert.Equal(t, 2, model.staleUpdates)
+	assert.Equal(t, 4, len(model.docs))
+
+	// The 3rd change triggers a full recalculation.
+	model.AddDoc(40, vectors.SparseVector{{Id: 2, Value: 1}})
+	assert.Equal(t, 0, model.staleUpdates)
+	assert.Equal(t, 4, len(model.docs))
+	assert.Equal(t, model.Scheme.idf(2, 4), model.idf[2])
+	assert.Equal(t, []int{10, 30}, scoredItemIds(model.Search(vectors.SparseVector{{Id: 1, Value: 1}}, QueryOptions{})))
+}
+
+// Verifies that, by default, the corpus is only reindexed once the changes
+// amount to more than a tenth of it.
+func TestTFIDF_MaxStaleFraction(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	corpus := makeRandomCorpus(rng, 120, 100, 10)
+
+	model := NewTFIDF()
+	model.Logger = NopLogger
+	model.StopWordThreshold = 1.0
+	for docId, doc := range corpus[:100] {
+		model.AddDoc(docId, doc)
+	}
+	model.Train()
+
+	for docId := 100; docId < 111; docId++ {
+		model.AddDoc(docId, corpus[docId])
+		assert.Equal(t, docId-99, model.staleUpdates)
+	}
+	model.AddDoc(111, corpus[111])
+	assert.Equal(t, 0, model.staleUpdates)
+}
+
+// Verifies that documents added after training only use the vocabulary of the
+// training, so that terms which the stop word and pruning rules would have
+// removed cannot slip into the index.
+func TestTFIDF_AddDoc_afterTrain_vocabulary(t *testing.T) {
+	model := NewTFIDF()
+	model.Logger = NopLogger
+	model.StopWordIds = []int{1}
+	model.StopWordThreshold = 0.5
+	model.MinDocFreq = 2
+	model.MaxStaleUpdates = 0
+	for docId := 0; docId < 4; docId++ {
+		model.AddDoc(docId, vectors.SparseVector{{Id: 1, Value: 1}, {Id: 2, Value: 1}, {Id: 10 + docId, Value: 1}})
+	}
+	model.AddDoc(4, vectors.SparseVector{{Id: 3, Value: 1}, {Id: 10, Value: 1}})
+	model.AddDoc(5, vectors.SparseVector{{Id: 3, Value: 1}, {Id: 11, Value: 1}})
+	model.Train()
+	assert.Equal(t, map[int]int{3: 2, 10: 2, 11: 2}, model.df)
+
+	// Term 1 is a listed stop word, term 2 exceeds StopWordThreshold, term 12
+	// is rare, and term 20 is new.  Term 20 ends up in most of the documents,
+	// so it would be a stop word if the model were retrained.
+	tf := vectors.SparseVector{{Id: 1, Value: 5}, {Id: 2, Value: 1}, {Id: 3, Value: 1}, {Id: 12, Value: 1}, {Id: 20, Value: 1}}
+	for docId := 6; docId < 20; docId++ {
+		model.AddDoc(docId, tf)
+	}
+	assert.Equal(t, tf, model.docs[6].TF)
+	assert.Equal(t, 1, len(model.docs[6].TFIDF))
+	assert.Equal(t, 3, model.docs[6].TFIDF[0].Id)
+	assert.Equal(t, map[int]int{3: 16, 10: 2, 11: 2}, model.df)
+	for _, termId := range []int{1, 2, 12, 20} {
+		assert.Equal(t, []ScoredItem{}, model.SimilarDocsForText(vectors.SparseVector{{Id: termId, Value: 1}}))
+	}
+
+	// Removing every document that contains a term keeps it in the vocabulary.
+	assert.Nil(t, model.RemoveDoc(0))
+	assert.Nil(t, model.RemoveDoc(4))
+	assert.Equal(t, 0, model.df[10])
+	model.AddDoc(20, vectors.SparseVector{{Id: 10, Value: 1}})
+	assert.Equal(t, []int{20}, scoredItemIds(model.Search(vectors.SparseVector{{Id: 10, Value: 1}}, QueryOptions{})))
+
+	// Retraining learns the new terms, and applies the rules to them.
+	model.AddDoc(21, vectors.SparseVector{{Id: 30, Value: 1}})
+	model.AddDoc(22, vectors.SparseVector{{Id: 3, Value: 1}, {Id: 30, Value: 1}})
+	assert.Equal(t, []ScoredItem{}, model.SimilarDocsForText(vectors.SparseVector{{Id: 30, Value: 1}}))
+	stats := model.Train()
+	assert.Equal(t, 2, model.df[30])
+	assert.Equal(t, []int{21, 22}, scoredItemIds(model.Search(vectors.SparseVector{{Id: 30, Value: 1}}, QueryOptions{})))
+	assert.Equal(t, RemovedByStopWordThreshold, stats.StopWordRules[20])
+}
+
+func TestTFIDF_Search_afterUpdates(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	corpus := makeRandomCorpus(rng, 400, 1000, 20)
+
+	model := newUpdateTestModel(SMART{TF: 'l', IDF: 't', Norm: 'c'}, corpus[:300])
+	model.MaxStaleUpdates = 1000
+	for docId := 300; docId < len(corpus); docId++ {
+		model.AddDoc(docId, corpus[docId])
+		assert.Nil(t, model.RemoveDoc(docId-300))
+	}
+
+	for i := 0; i < 50; i++ {
+		query := makeRandomCorpus(rng, 1, 1000, 5)[0]
+		all := matchedItems(model.SimilarDocsForText(query))
+		expected := all
+		if len(expected) > 5 {
+			expected = expected[:5]
+		}
+		assert.Equal(t, expected, model.Search(query, QueryOptions{K: 5}))
+	}
+}
+
+// Does what newTestModel() does, but reindexes after every change, so that
+// the scores of an updated model are exact.
+func newUpdateTestModel(scheme SMART, corpus []vectors.SparseVector) *TFIDF {
+	model := newTestModel(scheme, corpus)
+	model.MaxStaleFraction = 0
+	return model
+}
+
+// Verifies that 2 models rank the documents identically for a set of random
+// queries.
+func assertSameRankings(t *testing.T, rng *rand.Rand, expected, actual *TFIDF, msg string) {
+	for i := 0; i < 20; i++ {
+		query := makeRandomCorpus(rng, 1, 1000, 5)[0]
+		expectedItems := expected.SimilarDocsForText(query)
+		actualItems := actual.SimilarDocsForText(query)
+
+		assert.Equal(t, scoredItemIds(expectedItems), scoredItemIds(actualItems), msg)
+		for j := range expectedItems {
+			assert.InDelta(t, expectedItems[j].Score, actualItems[j].Score, 1e-12, msg)
+		}
+	}
+}
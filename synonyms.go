@@ -0,0 +1,211 @@
+package gosim
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Specifies how a synonym filter emits the terms that a matched phrase maps to.
+type SynonymMode int
+
+const (
+	// The matched phrase is replaced by the term(s) it maps to.
+	SynonymReplace SynonymMode = iota
+
+	// The matched phrase is kept, and the term(s) it maps to are emitted
+	// alongside it.
+	SynonymExpand
+)
+
+// SynonymTable maps words and multi-word phrases to the normalized term(s) that
+// should reach the Dictionary in their place (e.g. "telly" -> "television").
+//
+// Tokens are matched against phrases exactly as written, so the entries of the
+// table should use the same case-folding as the tokenizer they're used with.
+type SynonymTable struct {
+	// rules[phrase] -> the mapping for the (space-separated) phrase.
+	rules map[string]*synonymRule
+
+	// The number of words in the longest phrase within rules.
+	maxPhraseLen int
+}
+
+type synonymRule struct {
+	replacements []string // terms emitted in SynonymReplace mode
+	expansions   []string // terms emitted in SynonymExpand mode
+}
+
+// Creates an empty SynonymTable.
+func NewSynonymTable() *SynonymTable {
+	return &SynonymTable{
+		rules: make(map[string]*synonymRule),
+	}
+}
+
+// Declares the specified phrases to be equivalent.  In SynonymReplace mode,
+// every phrase is replaced by the first one; in SynonymExpand mode, every
+// phrase expands to all of them.
+func (me *SynonymTable) AddEquivalent(phrases ...string) {
+	phrases = normalizePhrases(phrases)
+	if len(phrases) == 0 {
+		return
+	}
+
+	for _, phrase := range phrases {
+		me.addRule(phrase, phrases[:1], phrases)
+	}
+}
+
+// Declares that each of the from phrases maps to all of the to phrases (one-to-
+// one or one-to-many).  In SynonymExpand mode, the original phrase is kept as
+// well.
+func (me *SynonymTable) AddMapping(from []string, to []string) {
+	to = normalizePhrases(to)
+	for _, phrase := range normalizePhrases(from) {
+		me.addRule(phrase, to, append([]string{phrase}, to...))
+	}
+}
+
+// Returns the number of distinct phrases that have a mapping in this table.
+func (me *SynonymTable) Size() int {
+	return len(me.rules)
+}
+
+// Creates a TokenFilter that applies this table to a list of tokens.  Phrases
+// are matched greedily from left to right, preferring the longest phrase at
+// each position.  Multi-word terms are emitted as a single token with the words
+// separated by a space (e.g. "new york").
+func (me *SynonymTable) Filter(mode SynonymMode) TokenFilter {
+	return func(tokens []string) []string {
+		filtered := make([]string, 0, len(tokens))
+
+		for i := 0; i < len(tokens); {
+			rule, phraseLen := me.longestMatch(tokens[i:])
+			if rule == nil {
+				filtered = append(filtered, tokens[i])
+				i++
+				continue
+			}
+
+			if mode == SynonymExpand {
+				filtered = append(filtered, rule.expansions...)
+			} else {
+				filtered = append(filtered, rule.replacements...)
+			}
+			i += phraseLen
+		}
+
+		return filtered
+	}
+}
+
+// Returns the rule for the longest phrase that tokens begins with, along with
+// the number of tokens in that phrase.  Returns a nil rule if there is no match.
+func (me *SynonymTable) longestMatch(tokens []string) (*synonymRule, int) {
+	maxLen := me.maxPhraseLen
+	if len(tokens) < maxLen {
+		maxLen = len(tokens)
+	}
+
+	for phraseLen := maxLen; phraseLen >= 1; phraseLen-- {
+		if rule, found := me.rules[strings.Join(tokens[:phraseLen], " ")]; found {
+			return rule, phraseLen
+		}
+	}
+	return nil, 0
+}
+
+func (me *SynonymTable) addRule(phrase string, replacements, expansions []string) {
+	rule, found := me.rules[phrase]
+	if !found {
+		rule = &synonymRule{}
+		me.rules[phrase] = rule
+	}
+	rule.replacements = appendUnique(rule.replacements, replacements...)
+	rule.expansions = appendUnique(rule.expansions, expansions...)
+
+	if phraseLen := len(strings.Fields(phrase)); phraseLen > me.maxPhraseLen {
+		me.maxPhraseLen = phraseLen
+	}
+}
+
+// Loads a SynonymTable from a text file (see LoadSynonyms()).
+func LoadSynonymsFile(filePath string) (*SynonymTable, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return LoadSynonyms(file)
+}
+
+// Loads a SynonymTable from a text stream in which each line is one of:
+//
+//	tv, television, telly        equivalent phrases (see AddEquivalent())
+//	nyc, big apple => new york   explicit mapping (see AddMapping())
+//	# a comment
+//
+// Blank lines are ignored.
+func LoadSynonyms(r io.Reader) (*SynonymTable, error) {
+	table := NewSynonymTable()
+	scanner := bufio.NewScanner(r)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		sides := strings.Split(line, "=>")
+		switch len(sides) {
+		case 1:
+			table.AddEquivalent(strings.Split(sides[0], ",")...)
+		case 2:
+			from := normalizePhrases(strings.Split(sides[0], ","))
+			to := normalizePhrases(strings.Split(sides[1], ","))
+			if len(from) == 0 || len(to) == 0 {
+				return nil, fmt.Errorf("synonyms line %v: both sides of '=>' must be non-empty", lineNum)
+			}
+			table.AddMapping(from, to)
+		default:
+			return nil, fmt.Errorf("synonyms line %v: more than one '=>'", lineNum)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// Collapses the whitespace within each phrase and discards empty phrases.
+func normalizePhrases(phrases []string) []string {
+	normalized := make([]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		if phrase = strings.Join(strings.Fields(phrase), " "); phrase != "" {
+			normalized = append(normalized, phrase)
+		}
+	}
+	return normalized
+}
+
+// Appends the values that are not already present in list.
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
@@ -0,0 +1,103 @@
+package gosim
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func ExampleSynonymTable() {
+	synonyms, _ := LoadSynonyms(strings.NewReader(`
+		# Normalize all TV variants to "tv"
+		tv, television, telly
+		nyc, big apple => new york
+	`))
+
+	tokenize := WithFilters(MakeDefaultTokenizer(), synonyms.Filter(SynonymReplace))
+	fmt.Println(strings.Join(tokenize("Watching telly in the Big Apple"), " | "))
+	// Output:
+	// watching | tv | in | the | new york
+}
+
+func TestSynonymTable_Filter_replace(t *testing.T) {
+	table := NewSynonymTable()
+	table.AddEquivalent("tv", "television", "telly")
+	table.AddMapping([]string{"nyc", "big apple"}, []string{"new york"})
+	table.AddMapping([]string{"pc"}, []string{"personal computer", "computer"})
+	assert.Equal(t, 6, table.Size())
+
+	filter := table.Filter(SynonymReplace)
+	assert.Equal(t, []string{"tv", "tv", "tv"}, filter([]string{"tv", "television", "telly"}))
+	assert.Equal(t, []string{"in", "new york", "city"}, filter([]string{"in", "big", "apple", "city"}))
+	assert.Equal(t, []string{"personal computer", "computer"}, filter([]string{"pc"}))
+	assert.Equal(t, []string{"big", "apples"}, filter([]string{"big", "apples"}))
+	assert.Equal(t, []string{}, filter([]string{}))
+}
+
+func TestSynonymTable_Filter_expand(t *testing.T) {
+	table := NewSynonymTable()
+	table.AddEquivalent("tv", "television", "telly")
+	table.AddMapping([]string{"big apple"}, []string{"new york"})
+
+	filter := table.Filter(SynonymExpand)
+	assert.Equal(t, []string{"my", "tv", "television", "telly"}, filter([]string{"my", "telly"}))
+	assert.Equal(t, []string{"big apple", "new york"}, filter([]string{"big", "apple"}))
+}
+
+func TestSynonymTable_Filter_longestMatch(t *testing.T) {
+	table := NewSynonymTable()
+	table.AddMapping([]string{"new"}, []string{"fresh"})
+	table.AddMapping([]string{"new york"}, []string{"nyc"})
+	table.AddMapping([]string{"new york times"}, []string{"nyt"})
+
+	filter := table.Filter(SynonymReplace)
+	assert.Equal(t, []string{"nyt", "in", "nyc", "fresh"}, filter([]string{"new", "york", "times", "in", "new", "york", "new"}))
+}
+
+func TestSynonymTable_mergesRules(t *testing.T) {
+	table := NewSynonymTable()
+	table.AddMapping([]string{"car"}, []string{"automobile"})
+	table.AddMapping([]string{"car"}, []string{"vehicle", "automobile"})
+
+	assert.Equal(t, []string{"automobile", "vehicle"}, table.Filter(SynonymReplace)([]string{"car"}))
+	assert.Equal(t, []string{"car", "automobile", "vehicle"}, table.Filter(SynonymExpand)([]string{"car"}))
+}
+
+func TestLoadSynonyms(t *testing.T) {
+	table, err := LoadSynonyms(strings.NewReader(`
+		# comment line
+		tv,television ,  telly
+
+		nyc , big    apple=>new york
+	`))
+	if assert.Nil(t, err) {
+		assert.Equal(t, 5, table.Size())
+		assert.Equal(t, []string{"tv", "new york"}, table.Filter(SynonymReplace)([]string{"telly", "big", "apple"}))
+	}
+
+	_, err = LoadSynonyms(strings.NewReader("a => "))
+	assert.EqualError(t, err, "synonyms line 1: both sides of '=>' must be non-empty")
+
+	_, err = LoadSynonyms(strings.NewReader("a => b\na => b => c"))
+	assert.EqualError(t, err, "synonyms line 2: more than one '=>'")
+}
+
+func TestLoadSynonymsFile(t *testing.T) {
+	f, _ := ioutil.TempFile("/tmp", "gosim_test_")
+	synonymsFilePath := f.Name()
+	defer os.Remove(synonymsFilePath)
+
+	f.WriteString("tv, telly\n")
+	f.Close()
+
+	table, err := LoadSynonymsFile(synonymsFilePath)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"tv"}, table.Filter(SynonymReplace)([]string{"telly"}))
+	}
+
+	_, err = LoadSynonymsFile("/a/b/c/nonexistent-file-xxxxxxxxxx.txt")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,100 @@
+package gosim
+
+import (
+	"bufio"
+	"io"
+	"unicode/utf8"
+)
+
+// TokenScanner reads text from an io.Reader and yields its tokens one at a time,
+// so that arbitrarily large inputs can be tokenized without loading them into
+// memory.  The tokens are identical to those that MakeDefaultTokenizer() would
+// produce for the entire input.
+//
+// Usage follows bufio.Scanner:
+//
+//	scanner := NewTokenScanner(file)
+//	for scanner.Scan() {
+//	    token := scanner.Token()
+//	    ...
+//	}
+//	if err := scanner.Err(); err != nil {
+//	    ...
+//	}
+type TokenScanner struct {
+	scanner *bufio.Scanner
+	token   string
+}
+
+// Creates a TokenScanner that reads from r.
+func NewTokenScanner(r io.Reader) *TokenScanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanDefaultTokens)
+	return &TokenScanner{scanner: scanner}
+}
+
+// Sets the initial read buffer and the maximum token size (in bytes), just like
+// bufio.Scanner.Buffer().  Must be called before the first call to Scan().
+func (me *TokenScanner) Buffer(buf []byte, max int) {
+	me.scanner.Buffer(buf, max)
+}
+
+// Advances to the next token, which will then be available through Token().
+// Returns false when the end of the input is reached or an error occurs.
+func (me *TokenScanner) Scan() bool {
+	for me.scanner.Scan() {
+		if token, ok := normalizeDefaultToken(me.scanner.Text()); ok {
+			me.token = token
+			return true
+		}
+	}
+
+	me.token = ""
+	return false
+}
+
+// Returns the most recent token produced by Scan().
+func (me *TokenScanner) Token() string {
+	return me.token
+}
+
+// Returns the first non-EOF error that was encountered by this TokenScanner.
+func (me *TokenScanner) Err() error {
+	return me.scanner.Err()
+}
+
+// A bufio.SplitFunc that produces the "coarse" tokens of the default tokenizer.
+// Multi-byte characters that straddle a buffer boundary are never split.
+func scanDefaultTokens(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	// Skip leading separators
+	start := 0
+	for start < len(data) {
+		if !atEOF && !utf8.FullRune(data[start:]) {
+			return start, nil, nil
+		}
+		c, width := utf8.DecodeRune(data[start:])
+		if isCoarseTokenRune(c) {
+			break
+		}
+		start += width
+	}
+
+	// Scan until the next separator
+	for i := start; i < len(data); {
+		if !atEOF && !utf8.FullRune(data[i:]) {
+			return start, nil, nil
+		}
+		c, width := utf8.DecodeRune(data[i:])
+		if !isCoarseTokenRune(c) {
+			return i + width, data[start:i], nil
+		}
+		i += width
+	}
+
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+
+	// Request more data
+	return start, nil, nil
+}
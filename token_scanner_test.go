@@ -0,0 +1,75 @@
+package gosim
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func ExampleTokenScanner() {
+	scanner := NewTokenScanner(strings.NewReader("Let's go bowling!"))
+	for scanner.Scan() {
+		fmt.Println(scanner.Token())
+	}
+	// Output:
+	// let's
+	// go
+	// bowling
+}
+
+func TestTokenScanner(t *testing.T) {
+	texts := []string{
+		"",
+		" \n\t",
+		"Mom's and Dad's",
+		" Foo BAR \t baz!?  foo-bar\n",
+		`one "two" '''three''' 'four'`,
+		"a aa aaa",
+		"Ünïcödé façade — naïve café 日本語テキスト ok",
+		"invalid \xff\xfe utf-8 \xe6\x97 bytes",
+	}
+
+	tokenize := MakeDefaultTokenizer()
+	for _, text := range texts {
+		assert.Equal(t, tokenize(text), scanAll(t, strings.NewReader(text)), text)
+
+		// Force every multi-byte character to straddle a read boundary.
+		assert.Equal(t, tokenize(text), scanAll(t, iotest.OneByteReader(strings.NewReader(text))), text)
+	}
+}
+
+func TestTokenScanner_smallBuffer(t *testing.T) {
+	text := strings.Repeat("naïve café 日本語 x-ray ", 1000)
+
+	scanner := NewTokenScanner(iotest.HalfReader(strings.NewReader(text)))
+	scanner.Buffer(make([]byte, 0, 4), 64)
+
+	tokens := []string{}
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Token())
+	}
+	assert.Nil(t, scanner.Err())
+	assert.Equal(t, MakeDefaultTokenizer()(text), tokens)
+}
+
+func TestTokenScanner_tokenTooLong(t *testing.T) {
+	scanner := NewTokenScanner(bytes.NewReader(bytes.Repeat([]byte("a"), 100)))
+	scanner.Buffer(make([]byte, 0, 4), 16)
+
+	assert.False(t, scanner.Scan())
+	assert.NotNil(t, scanner.Err())
+}
+
+func scanAll(t *testing.T, r io.Reader) []string {
+	scanner := NewTokenScanner(r)
+	tokens := []string{}
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Token())
+	}
+	assert.Nil(t, scanner.Err())
+	return tokens
+}
@@ -1,8 +1,10 @@
 package gosim
 
 import (
+	"regexp"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // Function definition for transforming unstructured document text into a list
@@ -13,19 +15,13 @@ func MakeDefaultTokenizer() Tokenize {
 	return func(text string) []string {
 		// Pass 1: Split the string into "coarse" tokens
 		tokens := strings.FieldsFunc(text, func(c rune) bool {
-			return !(unicode.IsLetter(c) || unicode.IsNumber(c) || c == '\'' || c == '-')
+			return !isCoarseTokenRune(c)
 		})
 
 		// Pass 2: case-fold and trim non-alphanumeric characters.
 		filteredTokens := make([]string, 0, len(tokens))
 		for _, token := range tokens {
-			token = strings.ToLower(token) // case folding
-			token = strings.TrimFunc(token, func(c rune) bool {
-				return !(unicode.IsLetter(c) || unicode.IsNumber(c))
-			})
-
-			// Discard single-character tokens while we're at it.
-			if len(token) >= 2 {
+			if token, ok := normalizeDefaultToken(token); ok {
 				filteredTokens = append(filteredTokens, token)
 			}
 		}
@@ -33,3 +29,94 @@ func MakeDefaultTokenizer() Tokenize {
 		return filteredTokens
 	}
 }
+
+// Returns true if c can be part of a "coarse" token produced by the first pass
+// of the default tokenizer.
+func isCoarseTokenRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsNumber(c) || c == '\'' || c == '-'
+}
+
+// Performs the second pass of the default tokenizer on a coarse token.  Returns
+// false if the token should be discarded.
+func normalizeDefaultToken(token string) (string, bool) {
+	token = strings.ToLower(token) // case folding
+	token = strings.TrimFunc(token, func(c rune) bool {
+		return !(unicode.IsLetter(c) || unicode.IsNumber(c))
+	})
+
+	// Discard single-character tokens while we're at it.
+	return token, len(token) >= 2
+}
+
+// Options for MakeRegexpTokenizer().
+type RegexpTokenizerOptions struct {
+	// If true, the pattern matches the separators between tokens (like NLTK's
+	// RegexpTokenizer with gaps=True) rather than the tokens themselves.
+	Gaps bool
+
+	// If true, tokens are converted to lower case.
+	CaseFold bool
+
+	// Tokens shorter than this many characters (runes) are discarded.
+	MinLength int
+
+	// Tokens longer than this many characters (runes) are discarded.  A value
+	// of 0 means there is no upper limit.
+	MaxLength int
+}
+
+// Creates a Tokenize function that extracts tokens using the specified regular
+// expression.  By default, each match of pattern is a token; if opts.Gaps is
+// set, pattern instead describes the text between tokens.
+//
+// Returns an error if pattern is not a valid regular expression.
+func MakeRegexpTokenizer(pattern string, opts RegexpTokenizerOptions) (Tokenize, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(text string) []string {
+		var tokens []string
+		if opts.Gaps {
+			tokens = re.Split(text, -1)
+		} else {
+			tokens = re.FindAllString(text, -1)
+		}
+
+		filteredTokens := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			if token == "" {
+				continue
+			}
+
+			tokenLen := utf8.RuneCountInString(token)
+			if tokenLen < opts.MinLength || (opts.MaxLength > 0 && tokenLen > opts.MaxLength) {
+				continue
+			}
+
+			if opts.CaseFold {
+				token = strings.ToLower(token)
+			}
+			filteredTokens = append(filteredTokens, token)
+		}
+
+		return filteredTokens
+	}, nil
+}
+
+// Function definition for transforming a list of tokens, e.g. removing
+// stopwords or mapping synonyms.
+type TokenFilter func(tokens []string) []string
+
+// Creates a Tokenize function that passes the tokens produced by tokenize
+// through each of the specified filters, in order.
+func WithFilters(tokenize Tokenize, filters ...TokenFilter) Tokenize {
+	return func(text string) []string {
+		tokens := tokenize(text)
+		for _, filter := range filters {
+			tokens = filter(tokens)
+		}
+		return tokens
+	}
+}
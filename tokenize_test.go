@@ -60,3 +60,69 @@ func BenchmarkTokenize(b *testing.B) {
 	}
 	fmt.Printf("BenchmarkTokenize: totalTokens=%v\n", totalTokens)
 }
+
+func ExampleMakeRegexpTokenizer() {
+	// Treat chemical names such as "1,3-butadiene" as single tokens.
+	tokenize, _ := MakeRegexpTokenizer(`[\w,()-]+`, RegexpTokenizerOptions{CaseFold: true})
+	tokens := tokenize("Polymerization of 1,3-Butadiene")
+
+	for i, token := range tokens {
+		fmt.Printf("token %v: %v\n", i, token)
+	}
+	// Output:
+	// token 0: polymerization
+	// token 1: of
+	// token 2: 1,3-butadiene
+}
+
+func TestMakeRegexpTokenizer(t *testing.T) {
+	tokenize, err := MakeRegexpTokenizer(`[A-Za-z_][A-Za-z0-9_]*`, RegexpTokenizerOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"fmt", "Println", "x", "y_2"}, tokenize("fmt.Println(x + y_2)"))
+	assert.Equal(t, []string{}, tokenize(""))
+}
+
+func TestMakeRegexpTokenizer_gaps(t *testing.T) {
+	tokenize, err := MakeRegexpTokenizer(`[\s;]+`, RegexpTokenizerOptions{Gaps: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a=1", "b=2", "C=3"}, tokenize("  a=1; b=2;\tC=3 "))
+}
+
+func TestMakeRegexpTokenizer_options(t *testing.T) {
+	tokenize, err := MakeRegexpTokenizer(`\S+`, RegexpTokenizerOptions{
+		CaseFold:  true,
+		MinLength: 2,
+		MaxLength: 4,
+	})
+	assert.Nil(t, err)
+
+	// Lengths are measured in characters rather than bytes.
+	assert.Equal(t, []string{"ab", "abcd", "éééé"}, tokenize("A AB abcd ABCDE ÉÉÉÉ"))
+}
+
+func TestMakeRegexpTokenizer_invalidPattern(t *testing.T) {
+	_, err := MakeRegexpTokenizer(`[a-z`, RegexpTokenizerOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestWithFilters(t *testing.T) {
+	dropStopWords := func(tokens []string) []string {
+		filtered := []string{}
+		for _, token := range tokens {
+			if token != "the" {
+				filtered = append(filtered, token)
+			}
+		}
+		return filtered
+	}
+	reverse := func(tokens []string) []string {
+		for i, j := 0, len(tokens)-1; i < j; i, j = i+1, j-1 {
+			tokens[i], tokens[j] = tokens[j], tokens[i]
+		}
+		return tokens
+	}
+
+	tokenize := WithFilters(MakeDefaultTokenizer(), dropStopWords, reverse)
+	assert.Equal(t, []string{"dog", "lazy", "quick"}, tokenize("The quick, the lazy, the dog"))
+	assert.Equal(t, []string{"foo"}, WithFilters(MakeDefaultTokenizer())("foo"))
+}